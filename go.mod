@@ -12,6 +12,7 @@ require (
 	github.com/walterwanderley/sqlc-connect v0.3.4
 	github.com/walterwanderley/sqlc-grpc v0.19.5
 	github.com/walterwanderley/sqlc-http v0.1.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -24,5 +25,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240108191215-35c7eff3a6b1 // indirect
 	google.golang.org/grpc v1.60.1 // indirect
 	google.golang.org/protobuf v1.32.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
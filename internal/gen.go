@@ -24,6 +24,7 @@ type tmplCtx struct {
 	Structs     []Struct
 	GoQueries   []Query
 	SqlcVersion string
+	Engine      string
 
 	// TODO: Race conditions
 	SourceName string
@@ -39,6 +40,8 @@ type tmplCtx struct {
 	EmitAllEnumValues         bool
 	UsesCopyFrom              bool
 	UsesBatch                 bool
+	UsesEvents                bool
+	UsesCache                 bool
 	OmitSqlcVersion           bool
 	BuildTags                 string
 }
@@ -179,12 +182,15 @@ func generate(req *plugin.GenerateRequest, options *opts.Options, enums []Enum,
 		EmitAllEnumValues:         options.EmitAllEnumValues,
 		UsesCopyFrom:              usesCopyFrom(queries),
 		UsesBatch:                 usesBatch(queries),
+		UsesEvents:                usesEvents(queries),
+		UsesCache:                 usesCache(queries),
 		SQLDriver:                 parseDriver(options.SqlPackage),
 		Q:                         "`",
 		Package:                   options.Package,
 		Enums:                     enums,
 		Structs:                   structs,
 		SqlcVersion:               req.SqlcVersion,
+		Engine:                    req.Settings.Engine,
 		BuildTags:                 options.BuildTags,
 		OmitSqlcVersion:           options.OmitSqlcVersion,
 	}
@@ -304,6 +310,16 @@ func generate(req *plugin.GenerateRequest, options *opts.Options, enums []Enum,
 			return nil, err
 		}
 	}
+	if tctx.UsesEvents {
+		if err := execute("outbox.go", "outboxFile"); err != nil {
+			return nil, err
+		}
+	}
+	if tctx.UsesCache {
+		if err := execute("cache.go", "cacheFile"); err != nil {
+			return nil, err
+		}
+	}
 
 	files := map[string]struct{}{}
 	for _, gq := range queries {
@@ -324,7 +340,7 @@ func generate(req *plugin.GenerateRequest, options *opts.Options, enums []Enum,
 		})
 	}
 
-	serverFiles, err := serverFiles(req, options, enums, structs, queries)
+	serverFiles, err := serverFiles(req, options, enums, structs, queries, resp.Files)
 	if err != nil {
 		return nil, err
 	}
@@ -353,6 +369,24 @@ func usesBatch(queries []Query) bool {
 	return false
 }
 
+func usesEvents(queries []Query) bool {
+	for _, q := range queries {
+		if q.Event != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func usesCache(queries []Query) bool {
+	for _, q := range queries {
+		if q.Cache != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func checkNoTimesForMySQLCopyFrom(queries []Query) error {
 	for _, q := range queries {
 		if q.Cmd != metadata.CmdCopyFrom {
@@ -108,6 +108,10 @@ func (i *importer) Imports(filename string) [][]ImportSpec {
 		return mergeImports(i.copyfromImports())
 	case batchFileName:
 		return mergeImports(i.batchImports())
+	case "outbox.go":
+		return mergeImports(i.outboxImports())
+	case "cache.go":
+		return mergeImports(i.cacheImports())
 	default:
 		return mergeImports(i.queryImports(filename))
 	}
@@ -272,6 +276,7 @@ func (i *importer) modelImports() fileImports {
 	if len(i.Enums) > 0 {
 		std["fmt"] = struct{}{}
 		std["database/sql/driver"] = struct{}{}
+		std["encoding/json"] = struct{}{}
 	}
 
 	return sortedImports(std, pkg)
@@ -389,6 +394,13 @@ func (i *importer) queryImports(filename string) fileImports {
 		std["context"] = struct{}{}
 	}
 
+	for _, q := range gq {
+		if q.Cache != nil {
+			// the TTL of the cached rows
+			std["time"] = struct{}{}
+		}
+	}
+
 	sqlpkg := parseDriver(i.Options.SqlPackage)
 	if sqlcSliceScan() && !sqlpkg.IsPGX() {
 		std["strings"] = struct{}{}
@@ -435,6 +447,46 @@ func (i *importer) copyfromImports() fileImports {
 	return sortedImports(std, pkg)
 }
 
+func (i *importer) outboxImports() fileImports {
+	std := map[string]struct{}{
+		"context":             {},
+		"database/sql/driver": {},
+		"encoding/json":       {},
+		"fmt":                 {},
+	}
+	pkg := make(map[ImportSpec]struct{})
+	switch parseDriver(i.Options.SqlPackage) {
+	case SQLDriverPGXV4:
+		pkg[ImportSpec{Path: "github.com/jackc/pgx/v4"}] = struct{}{}
+	case SQLDriverPGXV5:
+		pkg[ImportSpec{Path: "github.com/jackc/pgx/v5"}] = struct{}{}
+	default:
+		std["database/sql"] = struct{}{}
+	}
+	return sortedImports(std, pkg)
+}
+
+func (i *importer) cacheImports() fileImports {
+	std := map[string]struct{}{
+		"container/list": {},
+		"context":        {},
+		"encoding/json":  {},
+		"sync":           {},
+		"sync/atomic":    {},
+		"time":           {},
+	}
+	pkg := make(map[ImportSpec]struct{})
+	switch parseDriver(i.Options.SqlPackage) {
+	case SQLDriverPGXV4:
+		pkg[ImportSpec{Path: "github.com/jackc/pgx/v4"}] = struct{}{}
+	case SQLDriverPGXV5:
+		pkg[ImportSpec{Path: "github.com/jackc/pgx/v5"}] = struct{}{}
+	default:
+		std["database/sql"] = struct{}{}
+	}
+	return sortedImports(std, pkg)
+}
+
 func (i *importer) batchImports() fileImports {
 	batchQueries := make([]Query, 0, len(i.Queries))
 	for _, q := range i.Queries {
@@ -52,8 +52,51 @@ type Options struct {
 	MigrationLib                string            `json:"migration_lib,omitempty" yaml:"migration_lib"`
 	SkipGoMod                   bool              `json:"skip_go_mod,omitempty" yaml:"skip_go_mod"`
 	ServerType                  string            `json:"server_type,omitempty" yaml:"server_type"`
+	EmitTypescriptClient        bool              `json:"emit_typescript_client,omitempty" yaml:"emit_typescript_client"`
+	EmitCli                     bool              `json:"emit_cli,omitempty" yaml:"emit_cli"`
+	TemplateDir                 string            `json:"template_dir,omitempty" yaml:"template_dir"`
+	Templates                   map[string]string `json:"templates,omitempty" yaml:"templates"`
+	ProtectedRegions            bool              `json:"protected_regions,omitempty" yaml:"protected_regions"`
+	SnapshotDir                 string            `json:"snapshot_dir,omitempty" yaml:"snapshot_dir"`
+	Snapshot                    map[string]string `json:"snapshot,omitempty" yaml:"snapshot"`
+	Auth                        string            `json:"auth,omitempty" yaml:"auth"`
+	AuthRequired                bool              `json:"auth_required,omitempty" yaml:"auth_required"`
+	Tenant                      string            `json:"tenant,omitempty" yaml:"tenant"`
+	TenantSetting               string            `json:"tenant_setting,omitempty" yaml:"tenant_setting"`
+	TenantParam                 string            `json:"tenant_param,omitempty" yaml:"tenant_param"`
 	SkipQueries                 string            `json:"skip_queries,omitempty" yaml:"skip_queries"`
 	Append                      bool              `json:"append,omitempty" yaml:"append"`
+	Packages                    []ServerPackage   `json:"packages,omitempty" yaml:"packages"`
+	Transactions                []Transaction     `json:"transactions,omitempty" yaml:"transactions"`
+	OutboxMigrationVersion      string            `json:"outbox_migration_version,omitempty" yaml:"outbox_migration_version"`
+	WebhookMigrationVersion     string            `json:"webhook_migration_version,omitempty" yaml:"webhook_migration_version"`
+}
+
+// ServerPackage describes a package generated by another sql block of the same
+// sqlc configuration that must be registered in the generated server.
+type ServerPackage struct {
+	Package                   string `json:"package" yaml:"package"`
+	Out                       string `json:"out" yaml:"out"`
+	EmitMethodsWithDbArgument bool   `json:"emit_methods_with_db_argument,omitempty" yaml:"emit_methods_with_db_argument"`
+}
+
+// Transaction is an endpoint running the queries of its steps, in order, in a
+// single transaction.
+type Transaction struct {
+	Name string `json:"name" yaml:"name"`
+	// Path is the http path of the endpoint, /transaction/<name> by default.
+	Path  string            `json:"path,omitempty" yaml:"path"`
+	Steps []TransactionStep `json:"steps" yaml:"steps"`
+}
+
+// TransactionStep calls a query of the transaction, once or once per item of
+// the request if it's repeated.
+type TransactionStep struct {
+	Query    string `json:"query" yaml:"query"`
+	Repeated bool   `json:"repeated,omitempty" yaml:"repeated"`
+	// Bind fills params of the query, by name, with the result of a previous
+	// step, like "CreateOrder.id" or "CreateOrder" for a single column.
+	Bind map[string]string `json:"bind,omitempty" yaml:"bind"`
 }
 
 type GlobalOptions struct {
@@ -105,6 +148,12 @@ func parseOpts(req *plugin.GenerateRequest) (*Options, error) {
 		}
 	}
 
+	for i := range options.Packages {
+		if options.Packages[i].Package == "" {
+			options.Packages[i].Package = filepath.Base(options.Packages[i].Out)
+		}
+	}
+
 	if options.QueryParameterLimit == nil {
 		options.QueryParameterLimit = new(int32)
 		*options.QueryParameterLimit = 1
@@ -136,6 +185,34 @@ func ValidateOpts(opts *Options) error {
 	if *opts.QueryParameterLimit < 0 {
 		return fmt.Errorf("invalid options: query parameter limit must not be negative")
 	}
+	packages := make(map[string]struct{})
+	for _, p := range opts.Packages {
+		if p.Out == "" {
+			return fmt.Errorf("invalid options: missing out path for package %q", p.Package)
+		}
+		if _, ok := packages[p.Package]; ok {
+			return fmt.Errorf("invalid options: package %q is declared more than once", p.Package)
+		}
+		packages[p.Package] = struct{}{}
+	}
+	transactions := make(map[string]struct{})
+	for _, t := range opts.Transactions {
+		if t.Name == "" {
+			return fmt.Errorf("invalid options: missing name of transaction")
+		}
+		if _, ok := transactions[t.Name]; ok {
+			return fmt.Errorf("invalid options: transaction %q is declared more than once", t.Name)
+		}
+		transactions[t.Name] = struct{}{}
+		if len(t.Steps) == 0 {
+			return fmt.Errorf("invalid options: transaction %q has no steps", t.Name)
+		}
+		for _, step := range t.Steps {
+			if step.Query == "" {
+				return fmt.Errorf("invalid options: missing query of a step of transaction %q", t.Name)
+			}
+		}
+	}
 
 	return nil
 }
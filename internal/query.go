@@ -266,6 +266,140 @@ type Query struct {
 	Arg          QueryValue
 	// Used for :copyfrom
 	Table *plugin.Identifier
+	// Used for :many annotated with "-- stream:"
+	Stream bool
+	// Used for :many annotated with "-- paginate:"
+	Page *Page
+	// Used for the mutating queries annotated with "-- event:"
+	Event *QueryEvent
+	// Used for :one and :many annotated with "-- cache:"
+	Cache *QueryCache
+	// Used for the mutating queries changing the tables of cached queries
+	Invalidate *QueryInvalidate
+}
+
+// QueryEvent is the change event written to the outbox by a mutating query,
+// in the transaction of the change. The statement runs in the unexported
+// run<Query> method, wrapped by the method of the query writing the event.
+type QueryEvent struct {
+	Topic string
+	// Args are the arguments passed to the run<Query> method.
+	Args string
+	// Result is the type returned with the error, empty for :exec.
+	Result string
+	// Payload is the map of the params, or of the returned row for :one, by
+	// column name.
+	Payload string
+}
+
+// QueryCache is the read-through cache of the rows of a query, stored until
+// the TTL expires or a query changing one of the tables succeeds. The
+// statement runs in the unexported run<Query> method, wrapped by the method of
+// the query reading the cache.
+type QueryCache struct {
+	// TTL is the Go expression of the time the rows are cached.
+	TTL string
+	// Tables are the tables read by the query.
+	Tables []string
+	// Args are the arguments passed to the run<Query> method, keying the
+	// cached rows.
+	Args string
+	// Result is the type of the rows returned with the error.
+	Result string
+}
+
+// QueryInvalidate is the invalidation of the cached queries reading the tables
+// changed by a query, after it succeeds.
+type QueryInvalidate struct {
+	Tables []string
+	// Args are the arguments passed to the run<Query> method.
+	Args string
+	// Result is the type returned with the error, empty for :exec.
+	Result string
+}
+
+// Wrapped reports if the statement of the query runs in the run<Query> method,
+// wrapped by the method of the query. The :copyfrom and :batch methods
+// invalidate the cache themselves.
+func (q Query) Wrapped() bool {
+	if q.Cmd == metadata.CmdCopyFrom || strings.HasPrefix(q.Cmd, ":batch") {
+		return false
+	}
+	return q.Event != nil || q.Cache != nil || q.Invalidate != nil
+}
+
+// RunName returns the name of the method running the statement of the query.
+func (q Query) RunName() string {
+	if q.Wrapped() {
+		return "run" + q.MethodName
+	}
+	return q.MethodName
+}
+
+// RunDoc returns the comment of the run<Query> method.
+func (q Query) RunDoc() string {
+	var does []string
+	if q.Event != nil {
+		does = append(does, "writes its event")
+	}
+	if q.Cache != nil {
+		does = append(does, "caches its rows")
+	}
+	if q.Invalidate != nil {
+		does = append(does, "invalidates the cached queries of its tables")
+	}
+	return fmt.Sprintf("%s runs the statement of %s, which %s.", q.RunName(), q.MethodName, strings.Join(does, " and "))
+}
+
+// Page describes the keyset pagination of a :many query. The query is
+// wrapped to return the rows after a cursor, ordered by the cursor columns.
+type Page struct {
+	// Cursor holds the columns identifying the position of a row.
+	Cursor   *Struct
+	FirstSQL string
+	NextSQL  string
+}
+
+// CursorParams returns the fields of the after cursor passed to NextSQL.
+func (p Page) CursorParams() string {
+	out := make([]string, len(p.Cursor.Fields))
+	for i, f := range p.Cursor.Fields {
+		out[i] = "after." + f.Name
+	}
+	return strings.Join(out, ", ")
+}
+
+// streamFormat returns the format of the "-- stream:" comment annotating a
+// query whose rows are streamed by the server.
+func streamFormat(comments []string) (string, bool) {
+	for _, doc := range comments {
+		if format, ok := strings.CutPrefix(strings.TrimSpace(doc), "stream:"); ok {
+			return strings.TrimSpace(format), true
+		}
+	}
+	return "", false
+}
+
+// eventTopic returns the topic of the "-- event:" comment annotating a query
+// whose changes are written to the outbox.
+func eventTopic(comments []string) (string, bool) {
+	for _, doc := range comments {
+		if topic, ok := strings.CutPrefix(strings.TrimSpace(doc), "event:"); ok {
+			return strings.TrimSpace(topic), true
+		}
+	}
+	return "", false
+}
+
+// cacheSpec returns the spec of the "-- cache:" comment annotating a query
+// whose rows are cached, like "ttl=30s".
+func cacheSpec(comments []string) (string, bool) {
+	for _, doc := range comments {
+		if spec, ok := strings.CutPrefix(strings.TrimSpace(doc), "cache:"); ok {
+			return strings.TrimSpace(spec), true
+		}
+	}
+	return "", false
 }
 
 func (q Query) hasRetType() bool {
@@ -3,8 +3,11 @@ package golang
 import (
 	"bufio"
 	"fmt"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/sqlc-dev/plugin-sdk-go/metadata"
 	"github.com/sqlc-dev/plugin-sdk-go/plugin"
@@ -185,6 +188,8 @@ func argName(name string) string {
 
 func buildQueries(req *plugin.GenerateRequest, options *opts.Options, structs []Struct) ([]Query, error) {
 	qs := make([]Query, 0, len(req.Queries))
+	// the tables changed by the mutating queries, by method name
+	changes := make(map[string][]string)
 	for _, query := range req.Queries {
 		if query.Name == "" {
 			continue
@@ -226,11 +231,18 @@ func buildQueries(req *plugin.GenerateRequest, options *opts.Options, structs []
 			Comments:     comments,
 			Table:        query.InsertIntoTable,
 		}
+		if query.Cmd == metadata.CmdMany {
+			_, gq.Stream = streamFormat(query.Comments)
+		}
 		sqlpkg := parseDriver(options.SqlPackage)
 
 		qpl := int(*options.QueryParameterLimit)
+		// the server fills the tenant field of the params struct
+		tenantScoped := options.TenantParam != "" && slices.ContainsFunc(query.Params, func(p *plugin.Parameter) bool {
+			return p.Column.GetName() == options.TenantParam
+		})
 
-		if len(query.Params) == 1 && qpl != 0 {
+		if len(query.Params) == 1 && qpl != 0 && !tenantScoped {
 			p := query.Params[0]
 			gq.Arg = QueryValue{
 				Name:      escape(paramName(p)),
@@ -259,7 +271,7 @@ func buildQueries(req *plugin.GenerateRequest, options *opts.Options, structs []
 				EmitPointer: options.EmitParamsStructPointers,
 			}
 
-			if len(query.Params) <= qpl {
+			if len(query.Params) <= qpl && !tenantScoped {
 				gq.Arg.Emit = false
 			}
 		}
@@ -323,12 +335,414 @@ func buildQueries(req *plugin.GenerateRequest, options *opts.Options, structs []
 			}
 		}
 
+		if columns, ok := paginateColumns(query.Comments); ok && query.Cmd == metadata.CmdMany {
+			if gq.Stream {
+				return nil, fmt.Errorf("%s: -- stream: and -- paginate: can't be combined", query.Name)
+			}
+			if gq.Arg.HasSqlcSlices() {
+				return nil, fmt.Errorf("%s: -- paginate: doesn't support sqlc.slice", query.Name)
+			}
+			page, err := buildPage(req, options, query, columns)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", query.Name, err)
+			}
+			gq.Page = page
+		}
+
+		if topic, ok := eventTopic(query.Comments); ok {
+			event, err := buildEvent(options, gq, topic)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", query.Name, err)
+			}
+			gq.Event = event
+		}
+
+		if spec, ok := cacheSpec(query.Comments); ok {
+			cache, err := buildCache(req, options, query, gq, spec)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", query.Name, err)
+			}
+			gq.Cache = cache
+		} else if changesRows(gq) {
+			changes[gq.MethodName] = queryTables(req, query, true)
+		}
+
 		qs = append(qs, gq)
 	}
+	setInvalidations(options, qs, changes)
 	sort.Slice(qs, func(i, j int) bool { return qs[i].MethodName < qs[j].MethodName })
 	return qs, nil
 }
 
+// paginateColumns returns the columns of the "-- paginate:" comment, like
+// "name, id" or "created_at desc, id desc".
+func paginateColumns(comments []string) ([]string, bool) {
+	for _, doc := range comments {
+		if columns, ok := strings.CutPrefix(strings.TrimSpace(doc), "paginate:"); ok {
+			var res []string
+			for _, col := range strings.Split(columns, ",") {
+				if col = strings.TrimSpace(col); col != "" {
+					res = append(res, col)
+				}
+			}
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+// buildEvent describes the event of a mutating query: the payload is built
+// from the row returned by a :one query, or from the params.
+func buildEvent(options *opts.Options, query Query, topic string) (*QueryEvent, error) {
+	if topic == "" || strings.ContainsAny(topic, " \t") {
+		return nil, fmt.Errorf("invalid -- event: topic %q", topic)
+	}
+	if options.EmitMethodsWithDbArgument {
+		// the event is written in a transaction begun from the db of the Queries
+		return nil, fmt.Errorf("-- event: can't be used with emit_methods_with_db_argument")
+	}
+	event := QueryEvent{Topic: topic, Args: runArgs(query)}
+	payload := query.Arg
+	switch query.Cmd {
+	case metadata.CmdExec:
+	case metadata.CmdExecRows, metadata.CmdExecLastId:
+		event.Result = "int64"
+	case metadata.CmdExecResult:
+		event.Result = "sql.Result"
+		if parseDriver(options.SqlPackage).IsPGX() {
+			event.Result = "pgconn.CommandTag"
+		}
+	case metadata.CmdOne:
+		if !isMutating(query.SQL) {
+			return nil, fmt.Errorf("-- event: requires an INSERT, UPDATE or DELETE statement for a :one query")
+		}
+		event.Result = query.Ret.DefineType()
+		payload = query.Ret
+		payload.Name = "i"
+	default:
+		return nil, fmt.Errorf("-- event: isn't supported by %s queries", query.Cmd)
+	}
+	event.Payload = eventPayload(payload)
+	return &event, nil
+}
+
+// isMutating reports if the statement of a query changes the rows.
+func isMutating(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "insert", "update", "delete":
+		return true
+	}
+	return false
+}
+
+// runArgs returns the arguments passed by the method of a query to its
+// run<Query> method.
+func runArgs(query Query) string {
+	var args []string
+	for _, a := range query.Arg.Pairs() {
+		args = append(args, a.Name)
+	}
+	return strings.Join(args, ", ")
+}
+
+// runResult returns the type returned with the error by the run<Query> method
+// of a query, empty for :exec.
+func runResult(options *opts.Options, query Query) string {
+	switch query.Cmd {
+	case metadata.CmdExecRows, metadata.CmdExecLastId:
+		return "int64"
+	case metadata.CmdExecResult:
+		if parseDriver(options.SqlPackage).IsPGX() {
+			return "pgconn.CommandTag"
+		}
+		return "sql.Result"
+	case metadata.CmdOne:
+		return query.Ret.DefineType()
+	case metadata.CmdMany:
+		return "[]" + query.Ret.DefineType()
+	}
+	return ""
+}
+
+// buildCache describes the read-through cache of a :one or :many query from
+// the "-- cache:" spec, like "ttl=30s".
+func buildCache(req *plugin.GenerateRequest, options *opts.Options, query *plugin.Query, gq Query, spec string) (*QueryCache, error) {
+	var ttl time.Duration
+	for _, opt := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "ttl":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid -- cache: ttl %q, use a duration like 30s or 5m", value)
+			}
+			ttl = d
+		default:
+			return nil, fmt.Errorf("unknown -- cache: option %q, use ttl=<duration>", opt)
+		}
+	}
+	switch {
+	case ttl == 0:
+		return nil, fmt.Errorf("-- cache: requires the ttl, like -- cache: ttl=30s")
+	case gq.Cmd != metadata.CmdOne && gq.Cmd != metadata.CmdMany:
+		return nil, fmt.Errorf("-- cache: isn't supported by %s queries", gq.Cmd)
+	case isMutating(gq.SQL):
+		return nil, fmt.Errorf("-- cache: requires a SELECT statement")
+	case gq.Event != nil:
+		return nil, fmt.Errorf("-- cache: and -- event: can't be combined")
+	case gq.Stream || gq.Page != nil:
+		return nil, fmt.Errorf("-- cache: can't be combined with -- stream: or -- paginate:")
+	case options.EmitMethodsWithDbArgument:
+		// the cache is bypassed by the transactions of the db of the Queries
+		return nil, fmt.Errorf("-- cache: can't be used with emit_methods_with_db_argument")
+	case options.TenantSetting != "":
+		// the queries run in the transaction of the request, bypassing the cache
+		return nil, fmt.Errorf("-- cache: can't be used with the tenant_setting option")
+	}
+	tables := queryTables(req, query, false)
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("-- cache: requires a query reading the tables of the schema")
+	}
+	return &QueryCache{
+		TTL:    durationExpr(ttl),
+		Tables: tables,
+		Args:   runArgs(gq),
+		Result: runResult(options, gq),
+	}, nil
+}
+
+// durationExpr returns the Go expression of a duration, like 30 * time.Second.
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+	}
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d", int64(d))
+}
+
+// changesRows reports if a query changes the rows of its tables.
+func changesRows(query Query) bool {
+	switch query.Cmd {
+	case metadata.CmdExec, metadata.CmdExecRows, metadata.CmdExecResult, metadata.CmdExecLastId,
+		metadata.CmdCopyFrom, metadata.CmdBatchExec:
+		return true
+	case metadata.CmdBatchMany, metadata.CmdBatchOne:
+		return isMutating(query.SQL)
+	case metadata.CmdOne, metadata.CmdMany:
+		return isMutating(query.SQL)
+	}
+	return false
+}
+
+var (
+	// tableRef matches the tables read by a statement
+	tableRef = regexp.MustCompile(`(?i)\b(?:from|join)\s+(?:only\s+)?([\w."` + "`" + `]+)`)
+	// tableChange matches the tables changed or read by a statement
+	tableChange = regexp.MustCompile(`(?i)\b(?:into|update|from|join)\s+(?:only\s+)?([\w."` + "`" + `]+)`)
+	identQuotes = strings.NewReplacer(`"`, "", "`", "")
+)
+
+// queryTables returns the sorted tables of the catalog used by a query: the
+// tables of the columns and params, and the tables named by the statement,
+// or changed by it.
+func queryTables(req *plugin.GenerateRequest, query *plugin.Query, changes bool) []string {
+	tableName := func(schema, name string) string {
+		if schema == "" || schema == req.Catalog.DefaultSchema {
+			return name
+		}
+		return schema + "." + name
+	}
+	catalog := make(map[string]string)
+	for _, schema := range req.Catalog.Schemas {
+		for _, t := range schema.Tables {
+			name := tableName(schema.Name, t.Rel.GetName())
+			catalog[strings.ToLower(name)] = name
+		}
+	}
+	var tables []string
+	add := func(name string) {
+		if t, ok := catalog[strings.ToLower(name)]; ok && !slices.Contains(tables, t) {
+			tables = append(tables, t)
+		}
+	}
+	if t := query.InsertIntoTable; t != nil {
+		add(tableName(t.Schema, t.Name))
+	}
+	for _, c := range query.Columns {
+		for _, t := range []*plugin.Identifier{c.Table, c.EmbedTable} {
+			if t != nil {
+				add(tableName(t.Schema, t.Name))
+			}
+		}
+	}
+	for _, p := range query.Params {
+		if t := p.Column.GetTable(); t != nil {
+			add(tableName(t.Schema, t.Name))
+		}
+	}
+	re := tableRef
+	if changes {
+		re = tableChange
+	}
+	for _, m := range re.FindAllStringSubmatch(query.Text, -1) {
+		parts := strings.Split(identQuotes.Replace(m[1]), ".")
+		if len(parts) == 1 {
+			add(parts[0])
+		} else {
+			add(tableName(parts[len(parts)-2], parts[len(parts)-1]))
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// setInvalidations sets the invalidation of the cached queries to the queries
+// changing their tables.
+func setInvalidations(options *opts.Options, queries []Query, changes map[string][]string) {
+	var cached []string
+	for _, q := range queries {
+		if q.Cache != nil {
+			cached = append(cached, q.Cache.Tables...)
+		}
+	}
+	if len(cached) == 0 {
+		return
+	}
+	for i, q := range queries {
+		var tables []string
+		for _, t := range changes[q.MethodName] {
+			if slices.Contains(cached, t) {
+				tables = append(tables, t)
+			}
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		queries[i].Invalidate = &QueryInvalidate{
+			Tables: tables,
+			Args:   runArgs(q),
+			Result: runResult(options, q),
+		}
+	}
+}
+
+// eventPayload returns the map literal of the fields of a value by column
+// name, or nil for an empty value.
+func eventPayload(v QueryValue) string {
+	if v.isEmpty() {
+		return "nil"
+	}
+	if v.Struct == nil {
+		return fmt.Sprintf("map[string]any{%q: %s}", v.DBName, escape(v.Name))
+	}
+	seen := make(map[string]int)
+	entries := make([]string, 0, len(v.Struct.Fields))
+	for _, f := range v.Struct.Fields {
+		key := f.DBName
+		if key == "" {
+			key = toSnakeCase(f.Name)
+		}
+		if seen[key]++; seen[key] > 1 {
+			// a column compared to several params
+			key = fmt.Sprintf("%s_%d", key, seen[key])
+		}
+		value := escape(v.VariableForField(f))
+		if v.Name == "i" {
+			value = "i." + f.Name
+		}
+		entries = append(entries, fmt.Sprintf("%q: %s", key, value))
+	}
+	return "map[string]any{" + strings.Join(entries, ", ") + "}"
+}
+
+func buildPage(req *plugin.GenerateRequest, options *opts.Options, query *plugin.Query, columns []string) (*Page, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("-- paginate: requires the columns ordering the rows")
+	}
+	cursor := &Struct{Name: query.Name + "Cursor"}
+	names := make([]string, 0, len(columns))
+	var desc bool
+	for i, col := range columns {
+		name, order, _ := strings.Cut(col, " ")
+		switch order = strings.ToLower(strings.TrimSpace(order)); {
+		case order != "" && order != "asc" && order != "desc":
+			return nil, fmt.Errorf("invalid order %q of the paginate column %q", order, name)
+		case i == 0:
+			desc = order == "desc"
+		case desc != (order == "desc"):
+			return nil, fmt.Errorf("the paginate columns must have the same order")
+		}
+		var column *plugin.Column
+		for j, c := range query.Columns {
+			if columnName(c, j) == name && c.EmbedTable == nil {
+				column = c
+				break
+			}
+		}
+		if column == nil {
+			return nil, fmt.Errorf("the paginate column %q isn't returned by the query", name)
+		}
+		if !column.NotNull {
+			// The row value comparison of the next page is never true for a
+			// NULL, so the rows with a NULL cursor would never be read.
+			return nil, fmt.Errorf("the paginate column %q is nullable", name)
+		}
+		names = append(names, name)
+		cursor.Fields = append(cursor.Fields, Field{
+			Name:   StructName(name, options),
+			DBName: name,
+			Type:   goType(req, options, column),
+			Tags:   map[string]string{"json": JSONTagName(name, options)},
+			Column: column,
+		})
+	}
+
+	var params int
+	for _, p := range query.Params {
+		params = max(params, int(p.Number))
+	}
+	placeholder := func(n int) string {
+		if req.Settings.Engine == "postgresql" {
+			return fmt.Sprintf("$%d", params+n)
+		}
+		return "?"
+	}
+	op, orderBy := ">", strings.Join(names, ", ")
+	if desc {
+		op, orderBy = "<", strings.Join(names, " DESC, ")+" DESC"
+	}
+	left, right := names[0], placeholder(1)
+	if len(names) > 1 {
+		after := make([]string, len(names))
+		for i := range names {
+			after[i] = placeholder(i + 1)
+		}
+		left = "(" + strings.Join(names, ", ") + ")"
+		right = "(" + strings.Join(after, ", ") + ")"
+	}
+	sql := strings.TrimSuffix(strings.TrimSpace(query.Text), ";")
+	return &Page{
+		Cursor:   cursor,
+		FirstSQL: fmt.Sprintf("SELECT * FROM (\n%s\n) AS page\nORDER BY %s\nLIMIT %s", sql, orderBy, placeholder(1)),
+		NextSQL: fmt.Sprintf("SELECT * FROM (\n%s\n) AS page\nWHERE %s %s %s\nORDER BY %s\nLIMIT %s",
+			sql, left, op, right, orderBy, placeholder(len(names)+1)),
+	}, nil
+}
+
 var cmdReturnsData = map[string]struct{}{
 	metadata.CmdBatchMany: {},
 	metadata.CmdBatchOne:  {},
@@ -7,8 +7,10 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"text/template"
@@ -23,37 +25,163 @@ import (
 	httptemplates "github.com/walterwanderley/sqlc-http/templates"
 )
 
-func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enum, structs []Struct, queries []Query) ([]*plugin.File, error) {
+func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enum, structs []Struct, queries []Query, dbFiles []*plugin.File) ([]*plugin.File, error) {
 	var (
 		tmplFS    fs.FS
 		tmplFuncs template.FuncMap
 	)
 
-	switch options.ServerType {
+	serverType := options.ServerType
+	if serverType == "" {
+		serverType = "http" // the default server type
+	}
+	if parseDriver(options.SqlPackage) == SQLDriverPGXV4 {
+		// the server opens a pgxpool.Pool of pgx/v5 or a *sql.DB
+		return nil, fmt.Errorf("the sql_package pgx/v4 isn't supported by the server. Choose 'pgx/v5' or 'database/sql'")
+	}
+	def, pkg, err := toServerDefinition(req, options, enums, structs, queries)
+	if err != nil {
+		return nil, err
+	}
+	authMethods, err := parseAuthMethods(options.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if authMethods.enabled() && serverType != "http" && serverType != "grpc" && serverType != "connect" {
+		return nil, fmt.Errorf("the auth option isn't supported by the %s server type. Choose 'http', 'grpc' or 'connect'", serverType)
+	}
+	if !authMethods.enabled() && len(pkg.Auth) > 0 {
+		// the services would be public, ignoring the comments
+		return nil, fmt.Errorf("the auth comments of the queries require the auth option")
+	}
+	if !authMethods.enabled() && options.AuthRequired {
+		return nil, fmt.Errorf("the auth_required option requires the auth option")
+	}
+	tenant, err := parseTenant(options, serverType, req.GetSettings().GetEngine(), authMethods, pkg.TenantParams)
+	if err != nil {
+		return nil, err
+	}
+	if err := tenant.checkPublic(pkg, options.AuthRequired); err != nil {
+		return nil, err
+	}
+	if len(pkg.Webhooks) > 0 && serverType != "http" && serverType != "grpc" && serverType != "connect" {
+		return nil, fmt.Errorf("the webhook comments aren't supported by the %s server type. Choose 'http', 'grpc' or 'connect'", serverType)
+	}
+	events := usesEvents(queries)
+	var outboxVersion string
+	if events && options.MigrationPath != "" {
+		if outboxVersion, err = migrationVersion("outbox_migration_version", options.OutboxMigrationVersion); err != nil {
+			return nil, err
+		}
+	}
+	var webhookVersion string
+	if len(pkg.Webhooks) > 0 && options.MigrationPath != "" {
+		if webhookVersion, err = migrationVersion("webhook_migration_version", options.WebhookMigrationVersion); err != nil {
+			return nil, err
+		}
+	}
+	switch serverType {
 	case "grpc":
 		tmplFS = grpctemplates.Files
-		tmplFuncs = grpctemplates.Funcs
+		tmplFuncs = grpcFuncs(grpctemplates.Funcs, pkg.Enums, pkg.Validators)
 	case "connect":
 		tmplFS = connecttemplates.Files
-		tmplFuncs = connecttemplates.Funcs
-	case "", "http": // the default server type
+		tmplFuncs = connectFuncs(connecttemplates.Funcs, pkg.Enums, pkg.Validators)
+	case "twirp":
+		grpcFS, err := serverTemplatesFS("grpc", grpctemplates.Files)
+		if err != nil {
+			return nil, err
+		}
+		tmplFS = twirpBaseTemplates(grpcFS, connecttemplates.Files)
+		tmplFuncs = grpcFuncs(grpctemplates.Funcs, pkg.Enums, pkg.Validators)
+	case "http":
 		tmplFS = httptemplates.Files
-		tmplFuncs = httptemplates.Funcs
+		tmplFuncs = typescriptFuncs(clientFuncs(httpFuncs(httptemplates.Funcs, pkg.Enums, pkg.Validators), pkg.Enums), pkg.Enums)
+	case "graphql":
+		tmplFS = graphqlBaseTemplates(httptemplates.Files)
+		tmplFuncs = graphqlFuncs(httptemplates.Funcs, pkg.Enums, pkg.Validators)
+	case "jsonrpc":
+		httpFS, err := serverTemplatesFS("http", httptemplates.Files)
+		if err != nil {
+			return nil, err
+		}
+		tmplFS = jsonrpcBaseTemplates(httpFS)
+		tmplFuncs = jsonrpcFuncs(httptemplates.Funcs, pkg.Enums, pkg.Validators)
+	case "mcp":
+		httpFS, err := serverTemplatesFS("http", httptemplates.Files)
+		if err != nil {
+			return nil, err
+		}
+		if tmplFS, err = mcpBaseTemplates(httpFS); err != nil {
+			return nil, err
+		}
+		tmplFuncs = mcpFuncs(httptemplates.Funcs, pkg.Enums, pkg.Validators)
 	default:
-		return nil, fmt.Errorf("invalid server_type %q. Choose 'connect', 'grpc' or 'http'", options.ServerType)
+		return nil, fmt.Errorf("invalid server_type %q. Choose 'connect', 'grpc', 'twirp', 'http', 'graphql', 'jsonrpc' or 'mcp'", options.ServerType)
+	}
+	tmplFS, err = serverTemplatesFS(serverType, tmplFS)
+	if err != nil {
+		return nil, err
+	}
+	if tmplFS, err = dbErrorsTemplatesFS(tmplFS); err != nil {
+		return nil, err
+	}
+	if authMethods.enabled() {
+		if tmplFS, err = authTemplatesFS(serverType, tmplFS); err != nil {
+			return nil, err
+		}
+	}
+	if tenant != nil {
+		if tmplFS, err = tenantTemplatesFS(serverType, tmplFS); err != nil {
+			return nil, err
+		}
+	}
+	if events {
+		if tmplFS, err = outboxTemplatesFS(tmplFS); err != nil {
+			return nil, err
+		}
 	}
-	def := toServerDefinition(req, options, enums, structs, queries)
-	if err := def.Validate(); err != nil {
+	tmplFuncs = tenantFuncs(tmplFuncs, serverType, tenant, pkg, options.AuthRequired)
+	tmplFuncs = outboxFuncs(tmplFuncs, req.GetSettings().GetEngine(), events)
+	tmplFuncs = cacheFuncs(tmplFuncs, usesCache(queries))
+	if len(pkg.Webhooks) > 0 {
+		if tmplFS, err = webhookTemplatesFS(tmplFS); err != nil {
+			return nil, err
+		}
+	}
+	if authMethods.enabled() || tenant != nil || events || len(pkg.Webhooks) > 0 {
+		if tmplFS, err = registryTemplatesFS(serverType, tmplFS); err != nil {
+			return nil, err
+		}
+	}
+	if tmplFuncs, err = webhookFuncs(tmplFuncs, serverType, req.GetSettings().GetEngine(), pkg); err != nil {
+		return nil, err
+	}
+	tmplFuncs = authFuncs(tmplFuncs, serverType, authMethods.enabled(), options.AuthRequired, pkg)
+	if options.EmitCli && (serverType == "http" || serverType == "grpc") {
+		if tmplFS, err = cliTemplatesFS(serverType, tmplFS); err != nil {
+			return nil, err
+		}
+		tmplFuncs = cliFuncs(tmplFuncs, serverType, pkg)
+	}
+	if options.TemplateDir != "" || len(options.Templates) > 0 {
+		if tmplFS, err = customTemplatesFS(tmplFS, options.TemplateDir, options.Templates); err != nil {
+			return nil, err
+		}
+	}
+	// the stream and page services count as services of the package
+	if err := pkg.apiDefinition(def).Validate(); err != nil {
 		return nil, err
 	}
-	pkg := def.Packages[0]
 	depth := make([]string, 0)
 	for i := 0; i < len(strings.Split(req.GetSettings().GetCodegen().GetOut(), string(filepath.Separator))); i++ {
 		depth = append(depth, "..")
 	}
 	toRootPath := filepath.Join(depth...)
 	files := make([]*plugin.File, 0)
-	err := fs.WalkDir(tmplFS, ".", func(path string, d fs.DirEntry, err error) error {
+	// the editable files keep the protected regions edited by hand
+	editable := make([]*plugin.File, 0)
+	err = fs.WalkDir(tmplFS, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Println("ERROR ", err.Error())
 			return err
@@ -70,7 +198,7 @@ func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enu
 		}
 		log.Println(path, "...")
 		if strings.HasSuffix(newPath, "service.proto") {
-			protoContent, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, false)
+			protoContent, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg.protoPackage(), false)
 			if err != nil {
 				return err
 			}
@@ -83,11 +211,34 @@ func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enu
 		}
 
 		if strings.HasSuffix(newPath, "adapters.go") || strings.HasSuffix(newPath, "service.go") ||
-			strings.HasSuffix(newPath, "service.factory.go") || strings.HasSuffix(newPath, "routes.go") {
+			strings.HasSuffix(newPath, "service.factory.go") || strings.HasSuffix(newPath, "routes.go") ||
+			strings.HasSuffix(newPath, "service.batch.go") || strings.HasSuffix(newPath, "service.copyfrom.go") ||
+			strings.HasSuffix(newPath, "service.stream.go") || strings.HasSuffix(newPath, "service.page.go") ||
+			strings.HasSuffix(newPath, "service.validate.go") || strings.HasSuffix(newPath, "service.tx.go") ||
+			strings.HasSuffix(newPath, "schema.graphql") ||
+			strings.HasSuffix(newPath, "tools.json") {
 			if options.Append && strings.HasSuffix(newPath, "service.factory.go") {
 				return nil
 			}
-			content, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, true)
+			if strings.HasSuffix(newPath, "service.batch.go") && len(pkg.BatchServices) == 0 {
+				return nil
+			}
+			if strings.HasSuffix(newPath, "service.copyfrom.go") && len(pkg.CopyFromServices) == 0 {
+				return nil
+			}
+			if strings.HasSuffix(newPath, "service.stream.go") && len(pkg.StreamServices) == 0 {
+				return nil
+			}
+			if strings.HasSuffix(newPath, "service.page.go") && len(pkg.PageServices) == 0 {
+				return nil
+			}
+			if strings.HasSuffix(newPath, "service.validate.go") && len(pkg.Validators) == 0 {
+				return nil
+			}
+			if strings.HasSuffix(newPath, "service.tx.go") && len(pkg.TransactionServices) == 0 {
+				return nil
+			}
+			content, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, strings.HasSuffix(newPath, ".go"))
 			if err != nil {
 				return err
 			}
@@ -95,11 +246,71 @@ func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enu
 				Name:     newPath,
 				Contents: content,
 			})
+			if strings.HasSuffix(newPath, "service.factory.go") {
+				editable = append(editable, files[len(files)-1])
+			}
+			return nil
+		}
+
+		if strings.HasSuffix(newPath, "client/client.go") {
+			// the client is imported by other modules, it isn't internal
+			content, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, true)
+			if err != nil {
+				return err
+			}
+			files = append(files, &plugin.File{
+				Name:     filepath.Join(toRootPath, "client", pkg.Package, "client.go"),
+				Contents: content,
+			})
+			return nil
+		}
+
+		if strings.HasSuffix(newPath, "client/client.ts") {
+			if !options.EmitTypescriptClient {
+				return nil
+			}
+			content, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, false)
+			if err != nil {
+				return err
+			}
+			files = append(files, &plugin.File{
+				Name:     filepath.Join(toRootPath, "client", pkg.Package, "client.ts"),
+				Contents: content,
+			})
+			return nil
+		}
+
+		if strings.HasSuffix(newPath, "cmd/cli/commands.go") {
+			// the commands of each package are registered by its own file
+			content, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, true)
+			if err != nil {
+				return err
+			}
+			files = append(files, &plugin.File{
+				Name:     filepath.Join(toRootPath, "cmd", "cli", pkg.Package+".go"),
+				Contents: content,
+			})
+			return nil
+		}
+
+		if strings.HasSuffix(newPath, "openrpc.json") {
+			content, err := execServerTemplate(tmplFS, tmplFuncs, path, pkg, false)
+			if err != nil {
+				return err
+			}
+			files = append(files, &plugin.File{
+				Name:     filepath.Join(toRootPath, newPath),
+				Contents: content,
+			})
 			return nil
 		}
 
 		if strings.HasSuffix(newPath, "openapi.yml") {
-			content, err := execServerTemplate(tmplFS, tmplFuncs, path, &httpmetadata.EditableOpenApi{Definition: def}, false)
+			content, err := execServerTemplate(tmplFS, tmplFuncs, path, &httpmetadata.EditableOpenApi{
+				Definition:         pkg.apiDefinition(def),
+				UserDefinedPaths:   append(append(batchApiPaths(pkg), copyFromApiPaths(pkg)...), transactionApiPaths(pkg)...),
+				UserDefinedSchemas: batchApiComponentSchemas(pkg),
+			}, false)
 			if err != nil {
 				return err
 			}
@@ -130,11 +341,28 @@ func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enu
 			return nil
 		}
 
+		if (strings.HasSuffix(newPath, "auth/jwt.go") && !authMethods.JWT) || (strings.HasSuffix(newPath, "auth/apikey.go") && !authMethods.APIKey) {
+			return nil
+		}
+
+		if strings.HasSuffix(newPath, "tenant/db.go") && (tenant == nil || tenant.Setting == "") {
+			return nil
+		}
+
+		// the http responses aren't protobuf messages
+		if strings.HasSuffix(newPath, "webhook/proto.go") && serverType == "http" {
+			return nil
+		}
+
 		if (strings.HasSuffix(newPath, "litefs.go") || strings.HasSuffix(newPath, "forward.go")) && !def.LiteFS {
 			return nil
 		}
 
-		content, err := execServerTemplate(tmplFS, tmplFuncs, path, def, strings.HasSuffix(newPath, ".go"))
+		var data any = def
+		if filepath.Base(filepath.Dir(newPath)) == "dberrors" {
+			data = &dbErrors{Definition: def, Driver: dbErrorsDriver(def)}
+		}
+		content, err := execServerTemplate(tmplFS, tmplFuncs, path, data, strings.HasSuffix(newPath, ".go"))
 		if err != nil {
 			return err
 		}
@@ -142,23 +370,62 @@ func serverFiles(req *plugin.GenerateRequest, options *opts.Options, enums []Enu
 			Name:     filepath.Join(toRootPath, newPath),
 			Contents: content,
 		})
+		editable = append(editable, files[len(files)-1])
 
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	if events && def.MigrationPath != "" {
+		files = append(files, outboxMigrations(def, toRootPath, outboxVersion)...)
+	}
+	if len(pkg.Webhooks) > 0 && def.MigrationPath != "" {
+		files = append(files, webhookMigrations(def, toRootPath, webhookVersion)...)
+	}
+	index, err := newImportIndex(def.GoModule, req.GetSettings().GetCodegen().GetOut(), toRootPath, append(dbFiles, files...))
+	if err != nil {
+		return nil, fmt.Errorf("index imports: %w", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name, ".go") {
+			continue
+		}
+		f.Contents, err = organizeImports(f.Contents, def.GoModule, index)
+		if err != nil {
+			return nil, fmt.Errorf("organize imports of %s: %w", f.Name, err)
+		}
+	}
+	if options.ProtectedRegions {
+		// after organizing the imports, the imports of the regions are kept
+		snapshot, err := newRegionsSnapshot(options.SnapshotDir, options.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range editable {
+			if !strings.HasSuffix(f.Name, ".go") {
+				continue
+			}
+			if err := snapshot.protect(req.GetSettings().GetCodegen().GetOut(), f); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if !options.SkipGoMod {
+		goMod, err := goModFile(def.GoModule, options.ServerType, append(dbFiles, files...))
+		if err != nil {
+			return nil, fmt.Errorf("go.mod: %w", err)
+		}
 		files = append(files, &plugin.File{
 			Name:     filepath.Join(toRootPath, "go.mod"),
-			Contents: []byte(fmt.Sprintf("module %s\n", def.GoModule)),
+			Contents: goMod,
 		})
 	}
 
 	return files, nil
 }
 
-func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enums []Enum, structs []Struct, queries []Query) *metadata.Definition {
+func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enums []Enum, structs []Struct, queries []Query) (*metadata.Definition, *serverPackage, error) {
 	module := options.Module
 	if module == "" {
 		module = "my-project"
@@ -192,7 +459,24 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 		}
 		queriesToSkip = append(queriesToSkip, regexp.MustCompile(s))
 	}
+	serverEnums := make(serverEnums, 0, len(enums))
+	for _, e := range enums {
+		serverEnums = append(serverEnums, toServerEnum(e))
+	}
 	services := make([]*metadata.Service, 0)
+	validators := make(serverValidators, 0)
+	batchServices := make([]*batchService, 0)
+	copyFromServices := make([]*copyFromService, 0)
+	streamServices := make([]*streamService, 0)
+	pageServices := make([]*pageService, 0)
+	docs := make(map[string][]string)
+	auth := make(serverAuth)
+	webhooks := make(serverWebhooks)
+	tenantParams := make(map[string]*metadata.Field)
+	// unscoped are the queries annotated with "tenant: none", exposed to all
+	// the tenants without the tenant param
+	unscoped := make([]string, 0)
+	exposed := make([]string, 0)
 	var hasExecResult bool
 	for _, query := range queries {
 		var skip bool
@@ -205,16 +489,35 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 		if skip {
 			continue
 		}
+		isBatch := strings.HasPrefix(query.Cmd, ":batch")
+		// twirp has no streaming, the rows are returned as a list
+		isStream := query.Cmd == ":many" && query.Stream && options.ServerType != "twirp"
+		isPage := query.Cmd == ":many" && query.Page != nil
+		if (isBatch || query.Cmd == ":copyfrom") && query.Arg.isEmpty() {
+			continue
+		}
+		exposed = append(exposed, query.MethodName)
 		inputNames := make([]string, 0)
 		inputTypes := make([]string, 0)
 		if query.Arg.Struct != nil {
 			fields := make([]*metadata.Field, 0)
 			for _, f := range query.Arg.Struct.Fields {
+				if options.TenantParam != "" && f.DBName == options.TenantParam {
+					// the tenant isn't read from the request
+					tenantParams[query.MethodName] = &metadata.Field{
+						Name: f.Name,
+						Type: f.Type,
+					}
+					continue
+				}
 				fields = append(fields, &metadata.Field{
 					Name: f.Name,
 					Type: f.Type,
 				})
 			}
+			if _, ok := tenantParams[query.MethodName]; ok && len(fields) == 0 && (isBatch || query.Cmd == ":copyfrom") {
+				return nil, nil, fmt.Errorf("query %s: the tenant param can't be the only param of a %s query", query.MethodName, query.Cmd)
+			}
 			msg := metadata.Message{
 				Name:   query.Arg.Struct.Name,
 				Fields: fields,
@@ -249,7 +552,7 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 			retField := metadata.Field{
 				Name: "value",
 			}
-			isArray := query.Cmd == ":many"
+			isArray := (query.Cmd == ":many" && !isStream) || query.Cmd == ":batchmany"
 			if isArray {
 				retField.Name = "list"
 			} else {
@@ -278,6 +581,24 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 				Name: "value",
 				Type: typ,
 			})
+		} else if name, ok := execCountFieldName(query.Cmd); ok {
+			retFields = append(retFields, &metadata.Field{
+				Name: name,
+				Type: "int64",
+			})
+		}
+		if isPage {
+			retFields = append(retFields, &metadata.Field{
+				Name: "nextPageToken",
+				Type: "string",
+			})
+		}
+		if isBatch {
+			// the response of each item of the batch
+			retFields = append(retFields, &metadata.Field{
+				Name: "error",
+				Type: "string",
+			})
 		}
 		retMessage := metadata.Message{
 			Name:   query.MethodName + "Response",
@@ -286,7 +607,7 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 		messages[retMessage.Name] = &retMessage
 		var out strings.Builder
 		if !query.Ret.isEmpty() {
-			if query.Cmd == ":many" {
+			if (query.Cmd == ":many" && !isStream) || query.Cmd == ":batchmany" {
 				out.WriteString("[]")
 			}
 			out.WriteString(query.Ret.Type())
@@ -296,11 +617,16 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 				typ = "pgconn.CommandTag"
 			}
 			out.WriteString(typ)
+		} else if _, ok := execCountFieldName(query.Cmd); ok {
+			out.WriteString("int64")
 		}
 		httpSpecs := make([]metadata.HttpSpec, 0)
 		customSpecs := make(map[string][]string)
 		for _, doc := range query.Comments {
 			doc = strings.TrimSpace(doc)
+			if isQueryDoc(doc) {
+				docs[query.MethodName] = append(docs[query.MethodName], doc)
+			}
 			if strings.HasPrefix(doc, "http: ") {
 				opts := strings.Split(strings.TrimPrefix(doc, "http: "), " ")
 				if len(opts) != 2 {
@@ -316,6 +642,43 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 					Method: httpMethod,
 					Path:   httpPath,
 				})
+			} else if spec, ok := strings.CutPrefix(doc, "auth:"); ok {
+				rule, err := parseAuthRule(spec)
+				if err != nil {
+					return nil, nil, fmt.Errorf("query %s: %w", query.MethodName, err)
+				}
+				if prev, ok := auth[query.MethodName]; ok {
+					if prev.Public != rule.Public {
+						return nil, nil, fmt.Errorf("query %s: the public auth comment can't be combined with the other auth comments", query.MethodName)
+					}
+					rule.Roles = append(prev.Roles, rule.Roles...)
+				}
+				auth[query.MethodName] = rule
+			} else if spec, ok := strings.CutPrefix(doc, "tenant:"); ok {
+				switch {
+				case strings.TrimSpace(spec) != "none":
+					return nil, nil, fmt.Errorf("query %s: invalid tenant comment %q, use \"tenant: none\"", query.MethodName, doc)
+				case options.TenantParam == "":
+					return nil, nil, fmt.Errorf("query %s: the tenant comment requires the tenant_param option", query.MethodName)
+				}
+				unscoped = append(unscoped, query.MethodName)
+			} else if spec, ok := strings.CutPrefix(doc, "webhook:"); ok {
+				switch {
+				case isBatch || query.Cmd == ":copyfrom":
+					return nil, nil, fmt.Errorf("query %s: the webhook comment isn't supported by %s queries", query.MethodName, query.Cmd)
+				case isStream || isPage:
+					// the rows aren't sent in a single response
+					return nil, nil, fmt.Errorf("query %s: the webhook comment isn't supported by the streamed or paginated queries", query.MethodName)
+				}
+				events, err := parseWebhookEvents(spec)
+				if err != nil {
+					return nil, nil, fmt.Errorf("query %s: %w", query.MethodName, err)
+				}
+				for _, event := range events {
+					if !slices.Contains(webhooks[query.MethodName], event) {
+						webhooks[query.MethodName] = append(webhooks[query.MethodName], event)
+					}
+				}
 			} else {
 				k, v, ok := strings.Cut(doc, ":")
 				if !ok {
@@ -324,7 +687,22 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 				customSpecs[k] = append(customSpecs[k], v)
 			}
 		}
-		services = append(services, &metadata.Service{
+		if !isStream {
+			// only :many queries are streamed
+			delete(customSpecs, "stream")
+		}
+		if !isPage {
+			delete(customSpecs, "paginate")
+		}
+		if v, ok := toServerValidator(query, serverEnums); ok {
+			if f, ok := tenantParams[query.MethodName]; ok {
+				v.Fields = slices.DeleteFunc(v.Fields, func(vf *validatedField) bool { return vf.Name == f.Name })
+			}
+			if len(v.Fields) > 0 {
+				validators = append(validators, v)
+			}
+		}
+		svc := &metadata.Service{
 			Name:        query.MethodName,
 			Sql:         query.SQL,
 			Messages:    messages,
@@ -333,11 +711,84 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 			InputTypes:  inputTypes,
 			HttpSpecs:   httpSpecs,
 			CustomSpecs: customSpecs,
-		})
+		}
+		if isBatch {
+			var resultType string
+			if !query.Ret.isEmpty() {
+				resultType = query.Ret.DefineType()
+			}
+			batchServices = append(batchServices, &batchService{
+				Service:    svc,
+				Cmd:        query.Cmd,
+				ResultType: resultType,
+			})
+			continue
+		}
+		if query.Cmd == ":copyfrom" {
+			copyFromServices = append(copyFromServices, &copyFromService{Service: svc})
+			continue
+		}
+		if isPage {
+			pageServices = append(pageServices, &pageService{
+				Service:    svc,
+				CursorType: query.Page.Cursor.Name,
+				NextCursor: nextCursor(query),
+			})
+			continue
+		}
+		if isStream {
+			streamServices = append(streamServices, &streamService{
+				Service: svc,
+				RowType: query.Ret.DefineType(),
+			})
+			continue
+		}
+		services = append(services, svc)
+	}
+	if options.TenantParam != "" && options.TenantSetting == "" {
+		// without the row level security, only the tenant param scopes the
+		// queries to the tenant
+		for _, name := range exposed {
+			_, scoped := tenantParams[name]
+			switch {
+			case scoped && slices.Contains(unscoped, name):
+				return nil, nil, fmt.Errorf("query %s: the tenant comment can't annotate a query with the %s param", name, options.TenantParam)
+			case !scoped && !slices.Contains(unscoped, name):
+				return nil, nil, fmt.Errorf("query %s: the query has no %s param and would be called across the tenants. Add the param or annotate the query with \"-- tenant: none\"", name, options.TenantParam)
+			}
+		}
 	}
 	sort.SliceStable(services, func(i, j int) bool {
 		return strings.Compare(services[i].Name, services[j].Name) < 0
 	})
+	sort.SliceStable(batchServices, func(i, j int) bool {
+		return strings.Compare(batchServices[i].Name, batchServices[j].Name) < 0
+	})
+	sort.SliceStable(copyFromServices, func(i, j int) bool {
+		return strings.Compare(copyFromServices[i].Name, copyFromServices[j].Name) < 0
+	})
+	sort.SliceStable(streamServices, func(i, j int) bool {
+		return strings.Compare(streamServices[i].Name, streamServices[j].Name) < 0
+	})
+	sort.SliceStable(pageServices, func(i, j int) bool {
+		return strings.Compare(pageServices[i].Name, pageServices[j].Name) < 0
+	})
+	validators.sort()
+	transactionServices, err := toTransactionServices(options, services)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range pageServices {
+		// the page services keep the params message without the page fields
+		s.Messages = maps.Clone(messages)
+		params := converter.CanonicalName(s.Name + "Params")
+		if !s.EmptyInput() && s.HasCustomParams() {
+			params = converter.CanonicalName(s.InputTypes[0])
+		}
+		if m, ok := messages[params]; ok {
+			messages[params] = pageRequestMessage(m)
+		}
+	}
 	pkg := metadata.Package{
 		Messages:           messages,
 		Services:           services,
@@ -353,11 +804,30 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 		GoModule:           module,
 	}
 
+	packages := []*metadata.Package{&pkg}
+	for _, p := range options.Packages {
+		if p.Package == pkg.Package {
+			continue
+		}
+		packages = append(packages, &metadata.Package{
+			Engine:         pkg.Engine,
+			SqlPackage:     pkg.SqlPackage,
+			EmitDbArgument: p.EmitMethodsWithDbArgument,
+			Package:        p.Package,
+			SrcPath:        p.Out,
+			GoModule:       module,
+			Messages:       make(map[string]*metadata.Message),
+		})
+	}
+	// every sql block generates the same registry, so the order can't depend on
+	// the block being generated
+	sort.SliceStable(packages, func(i, j int) bool {
+		return strings.Compare(packages[i].Package, packages[j].Package) < 0
+	})
+
 	def := metadata.Definition{
-		GoModule: module,
-		Packages: []*metadata.Package{
-			&pkg,
-		},
+		GoModule:           module,
+		Packages:           packages,
 		LiteFS:             options.LiteFS,
 		Litestream:         options.Litestream,
 		DistributedTracing: options.Tracing,
@@ -366,12 +836,41 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 		MigrationLib:       migrationLib,
 	}
 
+	pkg.CustomProtoMessages = append(pkg.CustomProtoMessages, serverEnums.protoEnums()...)
+
 	outAdapters := make(map[string]struct{})
 
 	for _, s := range pkg.Services {
-		if s.HasCustomOutput() || s.HasArrayOutput() {
+		if _, ok := pkg.Messages[converter.CanonicalName(s.Output)]; ok && (s.HasCustomOutput() || s.HasArrayOutput()) {
+			outAdapters[converter.CanonicalName(s.Output)] = struct{}{}
+		}
+	}
+	for _, s := range batchServices {
+		if _, ok := s.outputMessage(); ok {
 			outAdapters[converter.CanonicalName(s.Output)] = struct{}{}
 		}
+		pkg.CustomProtoRPCs = append(pkg.CustomProtoRPCs, batchProtoRPCs(s, options.ServerType)...)
+		pkg.CustomProtoMessages = append(pkg.CustomProtoMessages, batchProtoMessages(s)...)
+	}
+	for _, s := range copyFromServices {
+		pkg.CustomProtoRPCs = append(pkg.CustomProtoRPCs, copyFromProtoRPCs(s, options.ServerType)...)
+		pkg.CustomProtoMessages = append(pkg.CustomProtoMessages, copyFromProtoMessages(s, options.ServerType)...)
+	}
+	for _, s := range streamServices {
+		if s.HasCustomOutput() {
+			outAdapters[converter.CanonicalName(s.Output)] = struct{}{}
+		}
+		pkg.CustomProtoRPCs = append(pkg.CustomProtoRPCs, streamProtoRPCs(s, options.ServerType)...)
+	}
+	for _, s := range pageServices {
+		if _, ok := pkg.Messages[converter.CanonicalName(s.Output)]; ok {
+			outAdapters[converter.CanonicalName(s.Output)] = struct{}{}
+		}
+		pkg.CustomProtoRPCs = append(pkg.CustomProtoRPCs, pageProtoRPCs(s, options.ServerType)...)
+	}
+	for _, t := range transactionServices {
+		pkg.CustomProtoRPCs = append(pkg.CustomProtoRPCs, transactionProtoRPCs(t, options.ServerType)...)
+		pkg.CustomProtoMessages = append(pkg.CustomProtoMessages, transactionProtoMessages(t)...)
 	}
 
 	pkg.OutputAdapters = make([]*metadata.Message, len(outAdapters))
@@ -385,7 +884,31 @@ func toServerDefinition(req *plugin.GenerateRequest, options *opts.Options, enum
 		return strings.Compare(pkg.OutputAdapters[i].Name, pkg.OutputAdapters[j].Name) < 0
 	})
 
-	return &def
+	return &def, &serverPackage{
+		metadataPackage:     &pkg,
+		BatchServices:       batchServices,
+		CopyFromServices:    copyFromServices,
+		StreamServices:      streamServices,
+		PageServices:        pageServices,
+		TransactionServices: transactionServices,
+		Enums:               serverEnums,
+		Validators:          validators,
+		Docs:                docs,
+		Auth:                auth,
+		Webhooks:            webhooks,
+		TenantParams:        tenantParams,
+		Unscoped:            unscoped,
+	}, nil
+}
+
+// isQueryDoc reports if the comment of a query documents it, instead of
+// annotating it like "http: GET /authors" or "paginate: id".
+func isQueryDoc(comment string) bool {
+	if comment == "" {
+		return false
+	}
+	key, _, ok := strings.Cut(comment, ":")
+	return !ok || strings.ContainsAny(strings.TrimSpace(key), " \t")
 }
 
 func execServerTemplate(fs fs.FS, funcs template.FuncMap, name string, data any, goSource bool) ([]byte, error) {
@@ -401,10 +924,14 @@ func execServerTemplate(fs fs.FS, funcs template.FuncMap, name string, data any,
 		return nil, err
 	}
 
-	t, err := template.New(name).Funcs(funcs).Parse(string(tmpl))
+	// the shared templates only define the templates executed by the others
+	t, err := template.New(name).Funcs(funcs).ParseFS(serverTemplates, "server_templates/shared/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
+	if t, err = t.Parse(string(tmpl)); err != nil {
+		return nil, err
+	}
 	err = t.Execute(&b, data)
 	if err != nil {
 		return nil, fmt.Errorf("execute template error: %w", err)
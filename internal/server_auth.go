@@ -0,0 +1,221 @@
+package golang
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// authMethods are the authentication methods enabled by the auth option, jwt
+// and api_key separated by comma.
+type authMethods struct {
+	JWT    bool
+	APIKey bool
+}
+
+func parseAuthMethods(option string) (authMethods, error) {
+	var res authMethods
+	for _, m := range strings.Split(option, ",") {
+		switch strings.TrimSpace(m) {
+		case "":
+		case "jwt":
+			res.JWT = true
+		case "api_key":
+			res.APIKey = true
+		default:
+			return res, fmt.Errorf("invalid auth method %q. Choose 'jwt', 'api_key' or both separated by comma", strings.TrimSpace(m))
+		}
+	}
+	return res, nil
+}
+
+func (m authMethods) enabled() bool {
+	return m.JWT || m.APIKey
+}
+
+// authRule authorizes the calls of a service, annotated by a comment like
+// "auth: role=admin,editor", "auth: authenticated" or "auth: public".
+type authRule struct {
+	// Roles are the roles allowed to call the service, any of them. Without
+	// roles, any authenticated caller is allowed.
+	Roles []string
+	// Public services are called without credentials, opting out of the
+	// auth_required option.
+	Public bool
+}
+
+// parseAuthRule parses the value of the auth comment: the word authenticated,
+// one or more role=<roles separated by comma>, or the word public.
+func parseAuthRule(spec string) (*authRule, error) {
+	rule := authRule{Roles: make([]string, 0)}
+	terms := strings.Fields(spec)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("empty auth comment, use \"auth: authenticated\", \"auth: role=<role>\" or \"auth: public\"")
+	}
+	if slices.Contains(terms, "public") {
+		if len(terms) > 1 {
+			return nil, fmt.Errorf("the public auth comment can't require %q", strings.Join(slices.DeleteFunc(terms, func(t string) bool { return t == "public" }), " "))
+		}
+		return &authRule{Public: true}, nil
+	}
+	for _, term := range terms {
+		if term == "authenticated" {
+			continue
+		}
+		roles, ok := strings.CutPrefix(term, "role=")
+		if !ok {
+			return nil, fmt.Errorf("invalid auth requirement %q, use authenticated, role=<role> or public", term)
+		}
+		for _, role := range strings.Split(roles, ",") {
+			if role == "" {
+				return nil, fmt.Errorf("invalid auth requirement %q, empty role", term)
+			}
+			if !slices.Contains(rule.Roles, role) {
+				rule.Roles = append(rule.Roles, role)
+			}
+		}
+	}
+	return &rule, nil
+}
+
+// serverAuth are the authorization rules of the services of the package, by
+// service name. The services without a rule are public, unless the
+// auth_required option is set.
+type serverAuth map[string]*authRule
+
+// rule returns the rule of the service, or nil if it's public.
+func (a serverAuth) rule(name string, required bool) *authRule {
+	rule, ok := a[name]
+	switch {
+	case ok && rule.Public:
+		return nil
+	case !ok && required:
+		return &authRule{}
+	}
+	return rule
+}
+
+// publicServices returns the names of the services of the package called
+// without credentials, logged by the server on startup. The transactions are
+// public if all their steps are.
+func (p *serverPackage) publicServices(required bool) []string {
+	res := make([]string, 0)
+	for _, name := range p.serviceNames() {
+		if !slices.ContainsFunc(p.steps(name), func(step string) bool { return p.Auth.rule(step, required) != nil }) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// steps returns the services called by the transaction with the name, or the
+// service with the name.
+func (p *serverPackage) steps(name string) []string {
+	for _, t := range p.TransactionServices {
+		if t.Name == name {
+			res := make([]string, 0, len(t.Steps))
+			for _, step := range t.Steps {
+				res = append(res, step.Name)
+			}
+			return res
+		}
+	}
+	return []string{name}
+}
+
+// serviceNames returns the names of the services and of the transactions of
+// the package.
+func (p *serverPackage) serviceNames() []string {
+	res := make([]string, 0)
+	add := func(name string) {
+		if !slices.Contains(res, name) {
+			res = append(res, name)
+		}
+	}
+	for _, s := range p.Services {
+		add(s.Name)
+	}
+	for _, s := range p.BatchServices {
+		add(s.Name)
+	}
+	for _, s := range p.CopyFromServices {
+		add(s.Name)
+	}
+	for _, s := range p.StreamServices {
+		add(s.Name)
+	}
+	for _, s := range p.PageServices {
+		add(s.Name)
+	}
+	for _, t := range p.TransactionServices {
+		add(t.Name)
+	}
+	return res
+}
+
+// authTemplatesFS adds the auth package and the middleware of the server type
+// to the templates.
+func authTemplatesFS(serverType string, base fs.FS) (fs.FS, error) {
+	common, err := fs.Sub(serverTemplates, "server_templates/auth/common")
+	if err != nil {
+		return nil, err
+	}
+	top, err := fs.Sub(serverTemplates, "server_templates/auth/"+serverType)
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: overlayFS{top: top, base: common}, base: base}, nil
+}
+
+// authFuncs adds the functions generating the authorization checks. The
+// Authorize function returns the check of a service, with the context and
+// the lines handling the error passed by the grpc and connect templates,
+// AuthEnabled reports if the middleware is generated and PublicServices
+// returns the services called without credentials. The checks of the
+// services of sqlc-grpc and sqlc-connect are added to their input.
+func authFuncs(funcs template.FuncMap, serverType string, enabled, required bool, pkg *serverPackage) template.FuncMap {
+	res := maps.Clone(funcs)
+	authorize := func(s *metadata.Service, args ...string) []string {
+		rule := pkg.Auth.rule(s.Name, required)
+		if rule == nil {
+			return nil
+		}
+		params := make([]string, 0, len(rule.Roles)+1)
+		for _, role := range rule.Roles {
+			params = append(params, strconv.Quote(role))
+		}
+		if serverType == "http" {
+			return []string{
+				fmt.Sprintf("if !auth.Authorize(%s) {", strings.Join(append([]string{"w", "r"}, params...), ", ")),
+				"return",
+				"}",
+			}
+		}
+		ctx, onError := "ctx", []string{"return nil, err"}
+		if len(args) > 0 {
+			ctx = args[0]
+		}
+		if len(args) > 1 {
+			onError = args[1:]
+		}
+		res := []string{fmt.Sprintf("if err := auth.Authorize(%s); err != nil {", strings.Join(append([]string{ctx}, params...), ", "))}
+		res = append(res, onError...)
+		return append(res, "}")
+	}
+	res["Authorize"] = authorize
+	res["AuthEnabled"] = func() bool { return enabled }
+	res["PublicServices"] = func() []string { return pkg.publicServices(required) }
+	if serverType == "grpc" || serverType == "connect" {
+		input := funcs["Input"].(func(*metadata.Service) []string)
+		res["Input"] = func(s *metadata.Service) []string {
+			return append(authorize(s), input(s)...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,343 @@
+package golang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// metadataPackage is embedded by serverPackage without shadowing the Package
+// field in the templates.
+type metadataPackage = metadata.Package
+
+// serverPackage is the template data of the files generated in the package of
+// the sql block.
+type serverPackage struct {
+	*metadataPackage
+	BatchServices    []*batchService
+	CopyFromServices []*copyFromService
+	StreamServices   []*streamService
+	PageServices     []*pageService
+	// TransactionServices are the transactions of the options, composing the
+	// regular services.
+	TransactionServices []*transactionService
+	Enums               serverEnums
+	Validators          serverValidators
+	// Docs are the comments of the queries, without the annotations, by
+	// service name.
+	Docs map[string][]string
+	Auth serverAuth
+	// Webhooks are the webhook events triggered by the services.
+	Webhooks serverWebhooks
+	// TenantParams are the tenant fields of the params structs, filled by the
+	// server instead of the requests, by service name.
+	TenantParams map[string]*metadata.Field
+	// Unscoped are the services annotated with "tenant: none", called without
+	// a tenant.
+	Unscoped []string
+}
+
+// batchService exposes a :batchexec, :batchone or :batchmany query. The
+// request is a list of the query parameters and the response has a result,
+// or an error, per item in the same order.
+type batchService struct {
+	*metadata.Service
+	Cmd string
+	// ResultType is the type of each row passed to the batch results callback.
+	ResultType string
+}
+
+// ResultsMethod returns the method of the <Name>BatchResults type generated
+// by sqlc to read the results.
+func (s *batchService) ResultsMethod() string {
+	switch s.Cmd {
+	case ":batchone":
+		return "QueryRow"
+	case ":batchmany":
+		return "Query"
+	}
+	return "Exec"
+}
+
+// ResultParam returns the parameter of the results callback receiving the
+// rows of an item.
+func (s *batchService) ResultParam() string {
+	switch s.Cmd {
+	case ":batchone":
+		return fmt.Sprintf("row %s, ", s.ResultType)
+	case ":batchmany":
+		return fmt.Sprintf("rows []%s, ", s.ResultType)
+	}
+	return ""
+}
+
+// BatchPath returns the http path of the batch endpoint, derived from the
+// first http spec of the query or from the query name. The name isn't
+// shortened like the other paths, CreateAuthors and GetAuthors would share
+// the same path.
+func (s *batchService) BatchPath() string {
+	if len(s.HttpSpecs) > 0 {
+		return "/" + strings.Trim(s.HttpSpecs[0].Path, "/") + "/batch"
+	}
+	return "/batch/" + converter.ToKebabCase(s.Name)
+}
+
+// valueField returns the field of the <Name>Response message holding the
+// result of an item.
+func (s *batchService) valueField() (*metadata.Field, bool) {
+	m, ok := s.Messages[s.Name+"Response"]
+	if !ok || len(m.Fields) < 2 {
+		return nil, false
+	}
+	return m.Fields[0], true
+}
+
+func (s *batchService) outputMessage() (*metadata.Message, bool) {
+	if s.EmptyOutput() {
+		return nil, false
+	}
+	m, ok := s.Messages[converter.CanonicalName(s.Output)]
+	return m, ok
+}
+
+func batchProtoRPCs(s *batchService, serverType string) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	rpc := fmt.Sprintf("rpc %s(%sBatchRequest) returns (%sBatchResponse)", name, name, name)
+	if serverType != "grpc" {
+		return []string{rpc + " { }"}
+	}
+	return []string{
+		rpc + " {",
+		"    option (google.api.http) = {",
+		fmt.Sprintf("        post: \"%s\"", s.BatchPath()),
+		"        body: \"*\"",
+		"    };",
+		"}",
+	}
+}
+
+func batchProtoMessages(s *batchService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	return []string{
+		"",
+		fmt.Sprintf("message %sBatchRequest {", name),
+		fmt.Sprintf("    repeated %sRequest items = 1;", name),
+		"}",
+		"",
+		fmt.Sprintf("message %sBatchResponse {", name),
+		fmt.Sprintf("    repeated %sResponse results = 1;", name),
+		"}",
+	}
+}
+
+// batchResultGrpc fills res.Results[i] with the rows of an item. It's shared
+// by the grpc and connect servers.
+func batchResultGrpc(s *batchService, enums serverEnums) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	f, ok := s.valueField()
+	if !ok {
+		return []string{fmt.Sprintf("res.Results[i] = &pb.%sResponse{}", name)}
+	}
+	attrName := converter.CamelCaseProto(f.Name)
+	adapter, convert := enums.adapter(s.Output)
+	if _, custom := s.outputMessage(); custom {
+		adapter, convert = "to"+converter.CanonicalName(s.Output), true
+	}
+	switch {
+	case s.Cmd == ":batchmany" && convert:
+		return []string{
+			fmt.Sprintf("item := new(pb.%sResponse)", name),
+			"for _, r := range rows {",
+			fmt.Sprintf("item.%s = append(item.%s, %s(r))", attrName, attrName, adapter),
+			"}",
+			"res.Results[i] = item",
+		}
+	case s.Cmd == ":batchmany":
+		return []string{fmt.Sprintf("res.Results[i] = &pb.%sResponse{%s: rows}", name, attrName)}
+	case convert:
+		return []string{fmt.Sprintf("res.Results[i] = &pb.%sResponse{%s: %s(row)}", name, attrName, adapter)}
+	}
+	return []string{fmt.Sprintf("res.Results[i] = &pb.%sResponse{%s: row}", name, attrName)}
+}
+
+func batchInputGrpc(s *batchService, enums serverEnums, validators serverValidators) []string {
+	return append(enums.inputGrpc(s.Service, "req"), validators.inputGrpc(s.Service)...)
+}
+
+// batchInputHttp converts the req item to the query parameters.
+func batchInputHttp(s *batchService) []string {
+	return itemInputHttp(s.Service, s.BatchPath())
+}
+
+// itemInputHttp converts a req item, decoded by the handler, to the query
+// parameters of the service exposed at the path.
+func itemInputHttp(s *metadata.Service, path string) []string {
+	svc := *s
+	svc.HttpSpecs = []metadata.HttpSpec{{Method: "POST", Path: path}}
+	// skip the request decoding
+	return httpmetadata.InputHttp(&svc)[3:]
+}
+
+func batchHandlerTypes(s *batchService) []string {
+	res := make([]string, 0)
+	res = append(res, "type request struct {")
+	res = append(res, httpmetadata.RequestTypeAttributes(s.Service)...)
+	res = append(res, "}")
+	_, custom := s.outputMessage()
+	if custom {
+		res = append(res, "type response struct {")
+		res = append(res, httpmetadata.ResponseTypeAttributes(s.Service)...)
+		res = append(res, "}")
+	}
+	res = append(res, "type result struct {")
+	if f, ok := s.valueField(); ok {
+		attrName := converter.UpperFirstCharacter(f.Name)
+		typ := "any"
+		switch {
+		case s.Cmd == ":batchmany" && custom:
+			typ = "[]response"
+		case custom:
+			typ = "*response"
+		}
+		res = append(res, fmt.Sprintf("%s %s `json:\"%s,omitempty\"`", attrName, typ, converter.ToSnakeCase(attrName)))
+	}
+	res = append(res, "Error string `json:\"error,omitempty\"`")
+	res = append(res, "}")
+	return res
+}
+
+// batchResultHttp fills res[i] with the rows of an item.
+func batchResultHttp(s *batchService) []string {
+	f, ok := s.valueField()
+	if !ok {
+		return nil
+	}
+	attrName := converter.UpperFirstCharacter(f.Name)
+	m, custom := s.outputMessage()
+	if !custom {
+		if s.Cmd == ":batchmany" {
+			return []string{fmt.Sprintf("res[i].%s = rows", attrName)}
+		}
+		return []string{fmt.Sprintf("res[i].%s = row", attrName)}
+	}
+	res := make([]string, 0)
+	if s.Cmd == ":batchmany" {
+		res = append(res, "list := make([]response, 0, len(rows))")
+		res = append(res, "for _, r := range rows {")
+		res = append(res, "var item response")
+		for _, f := range m.Fields {
+			res = append(res, httpmetadata.BindToSerializable("r", "item", converter.UpperFirstCharacter(f.Name), f.Type)...)
+		}
+		res = append(res, "list = append(list, item)")
+		res = append(res, "}")
+		res = append(res, fmt.Sprintf("res[i].%s = list", attrName))
+		return res
+	}
+	res = append(res, "var item response")
+	for _, f := range m.Fields {
+		res = append(res, httpmetadata.BindToSerializable("row", "item", converter.UpperFirstCharacter(f.Name), f.Type)...)
+	}
+	res = append(res, fmt.Sprintf("res[i].%s = &item", attrName))
+	return res
+}
+
+// batchApiPaths returns the OpenAPI paths of the batch endpoints.
+func batchApiPaths(pkg *serverPackage) []string {
+	res := make([]string, 0)
+	for _, s := range pkg.BatchServices {
+		svc := *s.Service
+		svc.HttpSpecs = []metadata.HttpSpec{{Method: "POST", Path: s.BatchPath()}}
+		res = append(res, fmt.Sprintf("%s:", s.BatchPath()))
+		res = append(res, "  post:")
+		res = append(res, "    tags:")
+		res = append(res, fmt.Sprintf("      - %s", pkg.Package))
+		res = append(res, fmt.Sprintf("    summary: %s", s.Name))
+		res = append(res, "    requestBody:")
+		res = append(res, "      content:")
+		res = append(res, "        application/json:")
+		res = append(res, "          schema:")
+		res = append(res, "            type: array")
+		res = append(res, "            items:")
+		res = append(res, indentSchema(pkg.Validators.apiSchema(&svc, pkg.Enums.apiSchema(httpmetadata.ApiParameters(&svc), pkg.Enums.serviceTypes(&svc))), "      schema:", 14)...)
+		res = append(res, "    responses:")
+		res = append(res, "      \"200\":")
+		res = append(res, "        description: OK")
+		res = append(res, "        content:")
+		res = append(res, "          application/json:")
+		res = append(res, "            schema:")
+		res = append(res, "              type: array")
+		res = append(res, "              items:")
+		res = append(res, "                type: object")
+		res = append(res, "                properties:")
+		if f, ok := s.valueField(); ok {
+			res = append(res, fmt.Sprintf("                  %s:", converter.ToSnakeCase(f.Name)))
+			schema := indentSchema(pkg.Enums.apiResponse(s.Service, httpmetadata.ApiResponse(s.Service)), "    schema:", 20)
+			if len(schema) == 0 {
+				schema = []string{"                    type: object"}
+			}
+			res = append(res, schema...)
+		}
+		res = append(res, "                  error:")
+		res = append(res, "                    type: string")
+		res = append(res, "      \"default\":")
+		res = append(res, "        description: Error message")
+		res = append(res, "        content:")
+		res = append(res, "          text/plain:")
+		res = append(res, "            schema:")
+		res = append(res, "              type: string")
+	}
+	return res
+}
+
+// batchApiComponentSchemas returns the OpenAPI schemas referenced by the batch
+// endpoints that aren't declared by the other services of the package.
+func batchApiComponentSchemas(pkg *serverPackage) []string {
+	declared := make(map[string]struct{})
+	for _, line := range httpmetadata.ApiComponentSchemas(pkg.apiPackage()) {
+		if !strings.HasPrefix(line, " ") {
+			declared[line] = struct{}{}
+		}
+	}
+	services := make([]*metadata.Service, 0, len(pkg.BatchServices))
+	for _, s := range pkg.BatchServices {
+		services = append(services, s.Service)
+	}
+	res := make([]string, 0)
+	var skip bool
+	for _, line := range pkg.Enums.apiComponentSchemas(pkg.Messages, httpmetadata.ApiComponentSchemas(&metadata.Package{Services: services})) {
+		if !strings.HasPrefix(line, " ") {
+			_, skip = declared[line]
+		}
+		if !skip {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// indentSchema returns the lines of the first schema declared at the
+// position of the header line, indented by the number of spaces.
+func indentSchema(lines []string, header string, indent int) []string {
+	res := make([]string, 0)
+	start := -1
+	for i, line := range lines {
+		if line == header {
+			start = i + 1
+			break
+		}
+	}
+	if start == -1 {
+		return res
+	}
+	base := len(header) - len(strings.TrimLeft(header, " ")) + 2
+	for _, line := range lines[start:] {
+		if len(line)-len(strings.TrimLeft(line, " ")) < base {
+			break
+		}
+		res = append(res, strings.Repeat(" ", indent)+line[base:])
+	}
+	return res
+}
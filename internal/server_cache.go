@@ -0,0 +1,14 @@
+package golang
+
+import (
+	"maps"
+	"text/template"
+)
+
+// cacheFuncs adds the CacheEnabled function, reporting to the transactions of
+// the services if the queries invalidate the cache after the commit.
+func cacheFuncs(funcs template.FuncMap, enabled bool) template.FuncMap {
+	res := maps.Clone(funcs)
+	res["CacheEnabled"] = func() bool { return enabled }
+	return res
+}
@@ -0,0 +1,338 @@
+package golang
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// cliTemplatesFS adds the templates of the command-line client to the
+// templates of the http and grpc servers. The runtime of the command is
+// shared, the commands call the server with the Go client of the http server
+// or with the gRPC client generated by buf.
+func cliTemplatesFS(serverType string, base fs.FS) (fs.FS, error) {
+	common, err := fs.Sub(serverTemplates, "server_templates/cli/common")
+	if err != nil {
+		return nil, err
+	}
+	top, err := fs.Sub(serverTemplates, "server_templates/cli/"+serverType)
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: overlayFS{top: top, base: common}, base: base}, nil
+}
+
+func cliFuncs(funcs template.FuncMap, serverType string, pkg *serverPackage) template.FuncMap {
+	res := maps.Clone(funcs)
+	cli := cliCommands{serverType: serverType, pkg: pkg.Package, enums: pkg.Enums, docs: pkg.Docs}
+	if serverType == "http" {
+		cli.handlerTypes = funcs["HandlerTypes"].(func(*metadata.Service) []string)
+	}
+	res["CliCommand"] = cli.command
+	res["CliBatchCommand"] = cli.batchCommand
+	res["CliCopyFromCommand"] = cli.copyFromCommand
+	res["CliStreamCommand"] = cli.streamCommand
+	res["CliPageCommand"] = cli.pageCommand
+	return res
+}
+
+// cliCommands builds the commands of the command-line client, one per query,
+// named after the query like get-author.
+type cliCommands struct {
+	serverType   string
+	pkg          string
+	enums        serverEnums
+	docs         map[string][]string
+	handlerTypes func(*metadata.Service) []string
+}
+
+// cliKind returns the kind of the flag setting a field of the Go type typ,
+// and if the flag is repeated.
+func (enums serverEnums) cliKind(typ string) (string, bool) {
+	typ = strings.TrimPrefix(typ, "*")
+	if typ == "[]byte" {
+		return "kindBytes", false
+	}
+	if elem, ok := strings.CutPrefix(typ, "[]"); ok {
+		kind, _ := enums.cliKind(elem)
+		return kind, true
+	}
+	if _, _, ok := enums.lookup(typ); ok {
+		return "kindEnum", false
+	}
+	switch typ {
+	case "string", "uuid.UUID", "net.IP", "net.HardwareAddr", "netip.Addr", "netip.Prefix",
+		"sql.NullString", "pgtype.Text", "pgtype.UUID":
+		return "kindString", false
+	case "bool", "sql.NullBool", "pgtype.Bool":
+		return "kindBool", false
+	case "int", "int8", "int16", "int32", "int64", "sql.NullInt16", "sql.NullInt32", "sql.NullInt64",
+		"pgtype.Int2", "pgtype.Int4", "pgtype.Int8":
+		return "kindInt", false
+	case "uint", "uint8", "uint16", "uint32", "uint64", "pgtype.Uint32":
+		return "kindUint", false
+	case "float32", "float64", "sql.NullFloat64", "pgtype.Float4", "pgtype.Float8":
+		return "kindFloat", false
+	case "time.Time", "sql.NullTime", "pgtype.Date", "pgtype.Timestamp", "pgtype.Timestamptz":
+		return "kindTime", false
+	}
+	return "kindJSON", false
+}
+
+// fields returns the declarations of the fields of the request, named like
+// the fields of the JSON and proto requests.
+func (c *cliCommands) fields(fields []clientField) []string {
+	res := make([]string, 0, len(fields))
+	for _, f := range fields {
+		kind, repeated := c.enums.cliKind(f.Type)
+		var b strings.Builder
+		fmt.Fprintf(&b, "{name: %q, kind: %s", f.Param, kind)
+		if repeated {
+			b.WriteString(", repeated: true")
+		}
+		if e, _, ok := c.enums.lookup(strings.TrimPrefix(f.Type, "*")); ok {
+			values := make([]string, 0, len(e.Values))
+			for _, v := range e.Values {
+				values = append(values, fmt.Sprintf("%q", v.Value))
+			}
+			fmt.Fprintf(&b, ", values: []string{%s}", strings.Join(values, ", "))
+		}
+		b.WriteString("},")
+		res = append(res, b.String())
+	}
+	return res
+}
+
+// usage describes the command with the comments of the query, or with the
+// endpoint called by the command.
+func (c *cliCommands) usage(s *metadata.Service, endpoint string) string {
+	if docs := c.docs[s.Name]; len(docs) > 0 {
+		return strings.Join(docs, " ")
+	}
+	if c.serverType == "grpc" {
+		return fmt.Sprintf("Calls the %s RPC.", converter.UpperFirstCharacter(s.Name))
+	}
+	return fmt.Sprintf("Calls %s.", endpoint)
+}
+
+func (c *cliCommands) declare(s *metadata.Service, endpoint string, fields []clientField, input bool, run []string) []string {
+	res := []string{
+		"&command{",
+		fmt.Sprintf("name: %q,", converter.ToKebabCase(s.Name)),
+		fmt.Sprintf("usage: %q,", c.usage(s, endpoint)),
+	}
+	if decls := c.fields(fields); len(decls) > 0 {
+		res = append(res, "fields: []*field{")
+		res = append(res, decls...)
+		res = append(res, "},")
+	}
+	if input {
+		res = append(res, "input: true,")
+	}
+	res = append(res, "run: func(ctx context.Context, a *args) error {")
+	res = append(res, run...)
+	return append(res, "},", "},")
+}
+
+// client returns the expression of the client of the package.
+func (c *cliCommands) client() string {
+	if c.serverType == "grpc" {
+		return fmt.Sprintf("pb.New%sServiceClient(conn)", converter.ToPascalCase(c.pkg))
+	}
+	return fmt.Sprintf("%s.New(serverURL)", c.pkg)
+}
+
+// decode returns the lines decoding the flags in the request req, and the
+// argument passing the request to the method of the client.
+func (c *cliCommands) decode(typ string, request bool) ([]string, string) {
+	if c.serverType == "grpc" {
+		return []string{
+			fmt.Sprintf("var req pb.%s", typ),
+			"if err := a.decode(&req); err != nil {",
+			"return err",
+			"}",
+		}, ", &req"
+	}
+	if !request {
+		return nil, ""
+	}
+	return []string{
+		fmt.Sprintf("var req %s.%s", c.pkg, typ),
+		"if err := a.decode(&req); err != nil {",
+		"return err",
+		"}",
+	}, ", req"
+}
+
+func (c *cliCommands) command(s *metadata.Service) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	endpoint := httpmetadata.HttpMethod(s) + " " + httpmetadata.HttpPath(s)
+	var request, empty bool
+	if c.serverType == "http" {
+		request = clientParams(name, c.handlerTypes(s)) != ""
+		empty = s.EmptyOutput()
+	}
+	run, arg := c.decode(name+"Request", request)
+	if empty {
+		run = append(run, fmt.Sprintf("return %s.%s(ctx%s)", c.client(), name, arg))
+		return c.declare(s, endpoint, clientFields(s), false, run)
+	}
+	run = append(run,
+		fmt.Sprintf("res, err := %s.%s(ctx%s)", c.client(), name, arg),
+		"if err != nil {",
+		"return err",
+		"}",
+		"return printResponse(res)",
+	)
+	return c.declare(s, endpoint, clientFields(s), false, run)
+}
+
+func (c *cliCommands) batchCommand(s *batchService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	run := []string{
+		"rows, err := a.rows()",
+		"if err != nil {",
+		"return err",
+		"}",
+	}
+	if c.serverType == "grpc" {
+		run = append(run,
+			fmt.Sprintf("var req pb.%sBatchRequest", name),
+			"for _, row := range rows {",
+			fmt.Sprintf("var item pb.%sRequest", name),
+			"if err := a.decodeRow(row, &item); err != nil {",
+			"return err",
+			"}",
+			"req.Items = append(req.Items, &item)",
+			"}",
+			fmt.Sprintf("res, err := %s.%s(ctx, &req)", c.client(), name),
+		)
+	} else {
+		run = append(run,
+			fmt.Sprintf("var items []%s.%sRequest", c.pkg, name),
+			"if err := decodeRows(rows, &items); err != nil {",
+			"return err",
+			"}",
+			fmt.Sprintf("res, err := %s.%s(ctx, items)", c.client(), name),
+		)
+	}
+	run = append(run,
+		"if err != nil {",
+		"return err",
+		"}",
+		"return printResponse(res)",
+	)
+	return c.declare(s.Service, "POST "+s.BatchPath(), clientFields(s.Service), true, run)
+}
+
+func (c *cliCommands) copyFromCommand(s *copyFromService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	run := []string{
+		"rows, err := a.rows()",
+		"if err != nil {",
+		"return err",
+		"}",
+	}
+	if c.serverType == "grpc" {
+		run = append(run,
+			fmt.Sprintf("stream, err := %s.%s(ctx)", c.client(), name),
+			"if err != nil {",
+			"return err",
+			"}",
+			"for _, row := range rows {",
+			fmt.Sprintf("var item pb.%sRequest", name),
+			"if err := a.decodeRow(row, &item); err != nil {",
+			"return err",
+			"}",
+			"if err := stream.Send(&item); err != nil {",
+			"return err",
+			"}",
+			"}",
+			"res, err := stream.CloseAndRecv()",
+		)
+	} else {
+		run = append(run,
+			fmt.Sprintf("var items []%s.%sRequest", c.pkg, name),
+			"if err := decodeRows(rows, &items); err != nil {",
+			"return err",
+			"}",
+			fmt.Sprintf("res, err := %s.%s(ctx, items)", c.client(), name),
+		)
+	}
+	run = append(run,
+		"if err != nil {",
+		"return err",
+		"}",
+		"return printResponse(res)",
+	)
+	return c.declare(s.Service, "POST "+s.BulkPath(), clientFields(s.Service), true, run)
+}
+
+func (c *cliCommands) streamCommand(s *streamService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	endpoint := httpmetadata.HttpMethod(s.Service) + " " + httpmetadata.HttpPath(s.Service)
+	request := c.serverType == "http" && clientParams(name, httpmetadata.HandlerTypes(s.Service)) != ""
+	run, arg := c.decode(name+"Request", request)
+	run = append(run, "p := newStreamPrinter()")
+	if c.serverType == "grpc" {
+		run = append(run,
+			fmt.Sprintf("stream, err := %s.%s(ctx%s)", c.client(), name, arg),
+			"if err != nil {",
+			"return err",
+			"}",
+			"for {",
+			"row, err := stream.Recv()",
+			"if errors.Is(err, io.EOF) {",
+			"return p.flush()",
+			"}",
+			"if err != nil {",
+			"return err",
+			"}",
+			"if err := p.print(row); err != nil {",
+			"return err",
+			"}",
+			"}",
+		)
+		return c.declare(s.Service, endpoint, clientFields(s.Service), false, run)
+	}
+	row := fmt.Sprintf("%s.%sResponse", c.pkg, name)
+	if !s.HasCustomOutput() {
+		row = c.enums.clientType(s.Output)
+		if _, _, ok := c.enums.lookup(strings.TrimPrefix(row, "*")); ok {
+			row = strings.Replace(row, strings.TrimPrefix(row, "*"), c.pkg+"."+strings.TrimPrefix(row, "*"), 1)
+		}
+	}
+	run = append(run,
+		fmt.Sprintf("err := %s.%s(ctx%s, func(row %s) error {", c.client(), name, arg, row),
+		"return p.print(row)",
+		"})",
+		"if err != nil {",
+		"return err",
+		"}",
+		"return p.flush()",
+	)
+	return c.declare(s.Service, endpoint, clientFields(s.Service), false, run)
+}
+
+func (c *cliCommands) pageCommand(s *pageService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	endpoint := httpmetadata.HttpMethod(s.Service) + " " + httpmetadata.HttpPath(s.Service)
+	fields := append(clientFields(s.Service),
+		clientField{Name: "PageSize", Type: "int32", Param: "page_size"},
+		clientField{Name: "PageToken", Type: "string", Param: "page_token"})
+	run, arg := c.decode(name+"Request", true)
+	run = append(run,
+		fmt.Sprintf("res, err := %s.%s(ctx%s)", c.client(), name, arg),
+		"if err != nil {",
+		"return err",
+		"}",
+		"return printResponse(res)",
+	)
+	return c.declare(s.Service, endpoint, fields, false, run)
+}
@@ -0,0 +1,438 @@
+package golang
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// clientFuncs adds the funcs of the Go client of the http server. The request
+// and response types of the client are the types of the handlers, so both
+// sides encode the same JSON.
+func clientFuncs(funcs template.FuncMap, enums serverEnums) template.FuncMap {
+	res := maps.Clone(funcs)
+	handlerTypes := funcs["HandlerTypes"].(func(*metadata.Service) []string)
+	res["ClientEnums"] = enums.clientEnums
+	res["ClientTypes"] = func(s *metadata.Service) []string {
+		return clientTypes(s.Name, handlerTypes(s), enums)
+	}
+	res["ClientMethod"] = func(s *metadata.Service) []string {
+		return clientMethod(s, handlerTypes(s), enums)
+	}
+	res["ClientBatchTypes"] = func(s *batchService) []string {
+		lines := batchHandlerTypes(s)
+		if f, ok := s.valueField(); ok {
+			if _, custom := s.outputMessage(); !custom {
+				lines = clientValueType(lines, converter.UpperFirstCharacter(f.Name)+" any ", converter.UpperFirstCharacter(f.Name)+" "+enums.clientType(f.Type)+" ")
+			}
+		}
+		return clientTypes(s.Name, lines, enums)
+	}
+	res["ClientBatchMethod"] = clientBatchMethod
+	res["ClientCopyFromTypes"] = func(s *copyFromService) []string {
+		return clientTypes(s.Name, copyFromHandlerTypes(s), enums)
+	}
+	res["ClientCopyFromMethod"] = clientCopyFromMethod
+	res["ClientStreamTypes"] = func(s *streamService) []string {
+		return clientTypes(s.Name, handlerTypes(s.Service), enums)
+	}
+	res["ClientStreamMethod"] = func(s *streamService) []string {
+		return clientStreamMethod(s, enums)
+	}
+	res["ClientPageTypes"] = func(s *pageService) []string {
+		lines := pageHandlerTypes(s)
+		if !s.HasCustomOutput() {
+			lines = clientValueType(lines, "List []any ", "List []"+enums.clientType(strings.TrimPrefix(s.Output, "[]"))+" ")
+		}
+		return clientTypes(s.Name, lines, enums)
+	}
+	res["ClientPageMethod"] = func(s *pageService) []string {
+		return clientPageMethod(s, enums)
+	}
+	return res
+}
+
+// clientEnums declares the enums of the package as strings, with the
+// constants generated by sqlc.
+func (enums serverEnums) clientEnums() []string {
+	res := make([]string, 0)
+	for _, e := range enums {
+		res = append(res, fmt.Sprintf("type %s string", e.Name))
+		res = append(res, "const (")
+		for _, v := range e.Values {
+			res = append(res, fmt.Sprintf("%s %s = %q", v.Constant, e.Name, v.Value))
+		}
+		res = append(res, ")")
+	}
+	return res
+}
+
+// clientType returns the type of the Go type typ in the JSON of the http
+// server, the type of the fields of the handlers. A Null<Enum> is a pointer
+// to the enum, as it's encoded as a string or null.
+func (enums serverEnums) clientType(typ string) string {
+	svc := metadata.Service{InputNames: []string{"value"}, InputTypes: []string{typ}}
+	// Value <type> `form:"value" json:"value"`
+	fields := strings.Fields(httpmetadata.RequestTypeAttributes(&svc)[0])
+	return enums.clientFieldType(fields[1])
+}
+
+func (enums serverEnums) clientFieldType(typ string) string {
+	slice := strings.HasPrefix(typ, "[]")
+	if e, null, ok := enums.lookup(typ); ok && null {
+		if slice {
+			return "[]*" + e.Name
+		}
+		return "*" + e.Name
+	}
+	return typ
+}
+
+var (
+	clientLocalType = regexp.MustCompile(`\b(request|response|result|page)\b`)
+	clientFormTag   = regexp.MustCompile(`form:"[^"]*" `)
+)
+
+// clientTypes exports the types declared by the handler of the service,
+// prefixing them with the name of the service: type response struct becomes
+// type <Name>Response struct. The form tags are dropped, the client only
+// sends JSON.
+func clientTypes(name string, lines []string, enums serverEnums) []string {
+	res := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(line, "type ") && len(res) > 0 {
+			res = append(res, "")
+		}
+		decl, tag, _ := strings.Cut(line, "`")
+		decl = clientLocalType.ReplaceAllStringFunc(decl, func(typ string) string {
+			return name + converter.UpperFirstCharacter(typ)
+		})
+		if fields := strings.Fields(decl); len(fields) == 2 && !strings.HasPrefix(decl, "type ") {
+			decl = fmt.Sprintf("%s %s ", fields[0], enums.clientFieldType(fields[1]))
+		}
+		if tag != "" {
+			tag = "`" + clientFormTag.ReplaceAllString(tag, "")
+		}
+		res = append(res, decl+tag)
+	}
+	return res
+}
+
+// clientValueType replaces the untyped field of the handler types, like the
+// list of a page of scalars, by its type.
+func clientValueType(lines []string, field, typed string) []string {
+	res := make([]string, 0, len(lines))
+	for _, line := range lines {
+		res = append(res, strings.Replace(line, field, typed, 1))
+	}
+	return res
+}
+
+// clientField is a field of a request, sent as a path or query parameter of
+// the GET and DELETE requests.
+type clientField struct {
+	Name string
+	Type string
+	// Param is the name of the path or query parameter.
+	Param string
+}
+
+func clientFields(s *metadata.Service) []clientField {
+	res := make([]clientField, 0)
+	if s.EmptyInput() {
+		return res
+	}
+	if s.HasCustomParams() {
+		m := s.Messages[converter.CanonicalName(s.InputTypes[0])]
+		for _, f := range m.Fields {
+			name := converter.UpperFirstCharacter(f.Name)
+			res = append(res, clientField{Name: name, Type: f.Type, Param: converter.ToSnakeCase(name)})
+		}
+		return res
+	}
+	for i, n := range s.InputNames {
+		name := converter.UpperFirstCharacter(n)
+		res = append(res, clientField{Name: name, Type: s.InputTypes[i], Param: converter.ToSnakeCase(name)})
+	}
+	return res
+}
+
+// clientFormat returns the expression formatting the value v, of the Go type
+// typ, like the server parses the path and query parameters.
+func clientFormat(v, typ string, enums serverEnums) string {
+	typ = strings.TrimPrefix(typ, "*")
+	if _, _, ok := enums.lookup(typ); ok {
+		return fmt.Sprintf("string(%s)", v)
+	}
+	switch typ {
+	case "string", "uuid.UUID", "net.HardwareAddr", "net.IP", "sql.NullString", "pgtype.Text", "pgtype.UUID":
+		return v
+	case "bool", "sql.NullBool", "pgtype.Bool":
+		return fmt.Sprintf("strconv.FormatBool(%s)", v)
+	case "int64", "sql.NullInt64", "pgtype.Int8":
+		return fmt.Sprintf("strconv.FormatInt(%s, 10)", v)
+	case "int", "int16", "int32", "sql.NullInt32", "pgtype.Int2", "pgtype.Int4":
+		return fmt.Sprintf("strconv.FormatInt(int64(%s), 10)", v)
+	case "uint16", "uint32", "uint64", "pgtype.Uint32":
+		return fmt.Sprintf("strconv.FormatUint(uint64(%s), 10)", v)
+	case "float32", "pgtype.Float4":
+		return fmt.Sprintf("strconv.FormatFloat(float64(%s), 'g', -1, 32)", v)
+	case "float64", "sql.NullFloat64", "pgtype.Float8":
+		return fmt.Sprintf("strconv.FormatFloat(%s, 'g', -1, 64)", v)
+	case "time.Time", "sql.NullTime", "pgtype.Timestamp", "pgtype.Timestamptz":
+		return fmt.Sprintf("%s.Format(time.RFC3339Nano)", v)
+	case "pgtype.Date":
+		return fmt.Sprintf("%s.Format(time.DateOnly)", v)
+	}
+	return fmt.Sprintf("fmt.Sprint(%s)", v)
+}
+
+// clientParam returns the lines passing the value of the field to set, a
+// function receiving the formatted value. The nil pointers aren't passed.
+func clientParam(f clientField, set func(string) string, enums serverEnums) []string {
+	typ := enums.clientType(f.Type)
+	v := "req." + f.Name
+	switch {
+	case strings.HasPrefix(typ, "*"):
+		return []string{
+			fmt.Sprintf("if %s != nil {", v),
+			set(clientFormat("*"+v, f.Type, enums)),
+			"}",
+		}
+	case typ == "[]byte" || typ == "json.RawMessage":
+		return []string{set(fmt.Sprintf("string(%s)", v))}
+	case strings.HasPrefix(typ, "[]"):
+		elem := strings.TrimPrefix(f.Type, "[]")
+		if strings.HasPrefix(strings.TrimPrefix(typ, "[]"), "*") {
+			return []string{
+				fmt.Sprintf("for _, v := range %s {", v),
+				"if v != nil {",
+				set(clientFormat("*v", elem, enums)),
+				"}",
+				"}",
+			}
+		}
+		return []string{
+			fmt.Sprintf("for _, v := range %s {", v),
+			set(clientFormat("v", elem, enums)),
+			"}",
+		}
+	}
+	return []string{set(clientFormat(v, f.Type, enums))}
+}
+
+var clientPathParam = regexp.MustCompile(`{([^}]*)}`)
+
+// clientVar returns the variable holding the value of the path parameter,
+// which can't shadow the other identifiers of the methods.
+func clientVar(param string) string {
+	v := converter.LowerFirstCharacter(converter.ToPascalCase(param))
+	switch v {
+	case "c", "ctx", "req", "res", "err", "fn", "path", "query", "url", "strconv", "time", "fmt":
+		return v + "Param"
+	}
+	return v
+}
+
+// clientRequest returns the lines building the path, with the path parameters
+// replaced by the fields of the request, and the query parameters of the GET
+// and DELETE requests. The other requests send the request as the body. The
+// path, query and body are returned as the arguments of Client.call.
+func clientRequest(method, path string, fields []clientField, enums serverEnums) ([]string, string) {
+	res := make([]string, 0)
+	params := make(map[string]struct{})
+	segments := make([]string, 0)
+	last := 0
+	for _, m := range clientPathParam.FindAllStringSubmatchIndex(path, -1) {
+		if m[0] > last {
+			segments = append(segments, fmt.Sprintf("%q", path[last:m[0]]))
+		}
+		last = m[1]
+		name := strings.TrimSuffix(path[m[2]:m[3]], "...")
+		if name == "$" || name == "" {
+			continue
+		}
+		params[name] = struct{}{}
+		segment := `""`
+		for _, f := range fields {
+			if f.Param != name {
+				continue
+			}
+			v := clientVar(name)
+			lines := clientParam(f, func(value string) string {
+				return fmt.Sprintf("%s = %s", v, value)
+			}, enums)
+			if len(lines) == 1 {
+				res = append(res, strings.Replace(lines[0], " = ", " := ", 1))
+			} else {
+				res = append(res, fmt.Sprintf("var %s string", v))
+				res = append(res, lines...)
+			}
+			segment = "url.PathEscape(" + v + ")"
+		}
+		segments = append(segments, segment)
+	}
+	if last < len(path) {
+		segments = append(segments, fmt.Sprintf("%q", path[last:]))
+	}
+	pathArg := strings.Join(segments, " + ")
+	if len(params) > 0 {
+		res = append(res, "path := "+pathArg)
+		pathArg = "path"
+	}
+	if method != "GET" && method != "DELETE" {
+		if len(fields) == 0 {
+			return res, pathArg + ", nil, nil"
+		}
+		return res, pathArg + ", nil, req"
+	}
+	query := make([]string, 0)
+	for _, f := range fields {
+		if _, ok := params[f.Param]; ok {
+			continue
+		}
+		set := func(v string) string {
+			return fmt.Sprintf("query.Add(%q, %s)", f.Param, v)
+		}
+		switch f.Name {
+		case "PageSize":
+			query = append(query, "if req.PageSize > 0 {", set(clientFormat("req.PageSize", f.Type, enums)), "}")
+		case "PageToken":
+			query = append(query, `if req.PageToken != "" {`, set("req.PageToken"), "}")
+		default:
+			query = append(query, clientParam(f, set, enums)...)
+		}
+	}
+	if len(query) == 0 {
+		return res, pathArg + ", nil, nil"
+	}
+	res = append(res, "query := make(url.Values)")
+	res = append(res, query...)
+	return res, pathArg + ", query, nil"
+}
+
+// clientParams returns the request parameter of the method, if the handler
+// declares a request type.
+func clientParams(name string, handlerTypes []string) string {
+	for _, line := range handlerTypes {
+		if line == "type request struct {" {
+			return fmt.Sprintf(", req %sRequest", name)
+		}
+	}
+	return ""
+}
+
+func clientMethod(s *metadata.Service, handlerTypes []string, enums serverEnums) []string {
+	method := httpmetadata.HttpMethod(s)
+	name := converter.UpperFirstCharacter(s.Name)
+	res, args := clientRequest(method, httpmetadata.HttpPath(s), clientFields(s), enums)
+	call := fmt.Sprintf("c.call(ctx, %q, %s", method, args)
+
+	var response bool
+	for _, line := range handlerTypes {
+		response = response || line == "type response struct {"
+	}
+	var ret string
+	switch {
+	case s.EmptyOutput():
+		ret = "error"
+		res = append(res, "return "+call+", nil)")
+	case response && s.HasArrayOutput():
+		ret = fmt.Sprintf("([]%sResponse, error)", name)
+		res = append(res, fmt.Sprintf("var res []%sResponse", name))
+		res = append(res, fmt.Sprintf("if err := %s, &res); err != nil {", call))
+		res = append(res, "return nil, err")
+		res = append(res, "}")
+		res = append(res, "return res, nil")
+	case response:
+		ret = fmt.Sprintf("(*%sResponse, error)", name)
+		res = append(res, fmt.Sprintf("var res %sResponse", name))
+		res = append(res, fmt.Sprintf("if err := %s, &res); err != nil {", call))
+		res = append(res, "return nil, err")
+		res = append(res, "}")
+		res = append(res, "return &res, nil")
+	case s.HasArrayOutput():
+		typ := "[]" + enums.clientType(strings.TrimPrefix(s.Output, "[]"))
+		ret = fmt.Sprintf("(%s, error)", typ)
+		res = append(res, "var res struct {")
+		res = append(res, fmt.Sprintf("List %s `json:\"list\"`", typ))
+		res = append(res, "}")
+		res = append(res, fmt.Sprintf("err := %s, &res)", call))
+		res = append(res, "return res.List, err")
+	default:
+		typ := enums.clientType(s.Output)
+		ret = fmt.Sprintf("(%s, error)", typ)
+		res = append(res, "var res struct {")
+		res = append(res, fmt.Sprintf("Value %s `json:\"value\"`", typ))
+		res = append(res, "}")
+		res = append(res, fmt.Sprintf("err := %s, &res)", call))
+		res = append(res, "return res.Value, err")
+	}
+	return clientFunc(name, clientParams(name, handlerTypes), ret, res)
+}
+
+func clientFunc(name, params, ret string, body []string) []string {
+	res := []string{fmt.Sprintf("func (c *Client) %s(ctx context.Context%s) %s {", name, params, ret)}
+	res = append(res, body...)
+	return append(res, "}")
+}
+
+func clientBatchMethod(s *batchService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	return clientFunc(name, fmt.Sprintf(", items []%sRequest", name), fmt.Sprintf("([]%sResult, error)", name), []string{
+		fmt.Sprintf("var res []%sResult", name),
+		fmt.Sprintf("if err := c.call(ctx, \"POST\", %q, nil, items, &res); err != nil {", s.BatchPath()),
+		"return nil, err",
+		"}",
+		"return res, nil",
+	})
+}
+
+func clientCopyFromMethod(s *copyFromService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	return clientFunc(name, fmt.Sprintf(", rows []%sRequest", name), fmt.Sprintf("(*%sResponse, error)", name), []string{
+		"body, err := encodeRecords(rows)",
+		"if err != nil {",
+		"return nil, err",
+		"}",
+		fmt.Sprintf("var res %sResponse", name),
+		fmt.Sprintf("if err := c.do(ctx, \"POST\", %q, nil, \"application/x-ndjson\", body, &res); err != nil {", s.BulkPath()),
+		"return nil, err",
+		"}",
+		"return &res, nil",
+	})
+}
+
+func clientStreamMethod(s *streamService, enums serverEnums) []string {
+	method := httpmetadata.HttpMethod(s.Service)
+	name := converter.UpperFirstCharacter(s.Name)
+	res, args := clientRequest(method, httpmetadata.HttpPath(s.Service), clientFields(s.Service), enums)
+	row := name + "Response"
+	if !s.HasCustomOutput() {
+		row = enums.clientType(s.Output)
+	}
+	res = append(res, fmt.Sprintf("return stream(ctx, c, %q, %s, fn)", method, args))
+	params := clientParams(name, httpmetadata.HandlerTypes(s.Service))
+	return clientFunc(name, fmt.Sprintf("%s, fn func(%s) error", params, row), "error", res)
+}
+
+func clientPageMethod(s *pageService, enums serverEnums) []string {
+	method := httpmetadata.HttpMethod(s.Service)
+	name := converter.UpperFirstCharacter(s.Name)
+	fields := append(clientFields(s.Service),
+		clientField{Name: "PageSize", Type: "int32", Param: "page_size"},
+		clientField{Name: "PageToken", Type: "string", Param: "page_token"})
+	res, args := clientRequest(method, httpmetadata.HttpPath(s.Service), fields, enums)
+	res = append(res, fmt.Sprintf("var res %sPage", name))
+	res = append(res, fmt.Sprintf("if err := c.call(ctx, %q, %s, &res); err != nil {", method, args))
+	res = append(res, "return nil, err")
+	res = append(res, "}")
+	res = append(res, "return &res, nil")
+	return clientFunc(name, fmt.Sprintf(", req %sRequest", name), fmt.Sprintf("(*%sPage, error)", name), res)
+}
@@ -0,0 +1,142 @@
+package golang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// copyFromService exposes a :copyfrom query. The rows are streamed by the
+// client and copied in chunks, so the request is never fully loaded in memory.
+type copyFromService struct {
+	*metadata.Service
+}
+
+// BulkPath returns the http path of the bulk-load endpoint, derived from the
+// first http spec of the query or from the query name.
+func (s *copyFromService) BulkPath() string {
+	if len(s.HttpSpecs) > 0 {
+		return "/" + strings.Trim(s.HttpSpecs[0].Path, "/") + "/bulk"
+	}
+	return "/bulk/" + converter.ToKebabCase(s.Name)
+}
+
+// ItemPointer reports if the rows of the generated CopyFrom method are
+// pointers.
+func (s *copyFromService) ItemPointer() bool {
+	return strings.HasPrefix(s.InputTypes[0], "*")
+}
+
+func copyFromProtoRPCs(s *copyFromService, serverType string) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	if serverType == "twirp" {
+		// twirp has no client streaming, the rows are sent in a single request
+		return []string{fmt.Sprintf("rpc %s(%sCopyFromRequest) returns (%sResponse) { }", name, name, name)}
+	}
+	rpc := fmt.Sprintf("rpc %s(stream %sRequest) returns (%sResponse)", name, name, name)
+	if serverType != "grpc" {
+		return []string{rpc + " { }"}
+	}
+	return []string{
+		rpc + " {",
+		"    option (google.api.http) = {",
+		fmt.Sprintf("        post: \"%s\"", s.BulkPath()),
+		"        body: \"*\"",
+		"    };",
+		"}",
+	}
+}
+
+func copyFromProtoMessages(s *copyFromService, serverType string) []string {
+	if serverType != "twirp" {
+		return nil
+	}
+	name := converter.UpperFirstCharacter(s.Name)
+	return []string{
+		"",
+		fmt.Sprintf("message %sCopyFromRequest {", name),
+		fmt.Sprintf("    repeated %sRequest items = 1;", name),
+		"}",
+	}
+}
+
+// copyFromInputGrpc converts a streamed message to a row. It's shared by the
+// grpc, connect and twirp servers.
+func copyFromInputGrpc(s *copyFromService, enums serverEnums, validators serverValidators) []string {
+	return append(enums.inputGrpc(s.Service, "req"), validators.inputGrpc(s.Service)...)
+}
+
+// copyFromDecodeGrpc declares the decode function returning the row of the
+// input lines.
+func copyFromDecodeGrpc(s *copyFromService, input []string) []string {
+	typ := converter.CanonicalName(s.InputTypes[0])
+	res := make([]string, 0)
+	res = append(res, fmt.Sprintf("decode := func(req *pb.%sRequest) (*%s, error) {", converter.UpperFirstCharacter(s.Name), typ))
+	res = append(res, input...)
+	if s.ItemPointer() {
+		res = append(res, fmt.Sprintf("return %s, nil", s.InputNames[0]))
+	} else {
+		res = append(res, fmt.Sprintf("return &%s, nil", s.InputNames[0]))
+	}
+	res = append(res, "}")
+	return res
+}
+
+func copyFromHandlerTypes(s *copyFromService) []string {
+	res := make([]string, 0)
+	res = append(res, "type request struct {")
+	res = append(res, httpmetadata.RequestTypeAttributes(s.Service)...)
+	res = append(res, "}")
+	res = append(res, "type response struct {")
+	res = append(res, "RowsAffected int64 `json:\"rows_affected\"`")
+	res = append(res, "}")
+	return res
+}
+
+func copyFromInputHttp(s *copyFromService) []string {
+	return itemInputHttp(s.Service, s.BulkPath())
+}
+
+// copyFromApiPaths returns the OpenAPI paths of the bulk-load endpoints.
+func copyFromApiPaths(pkg *serverPackage) []string {
+	res := make([]string, 0)
+	for _, s := range pkg.CopyFromServices {
+		svc := *s.Service
+		svc.HttpSpecs = []metadata.HttpSpec{{Method: "POST", Path: s.BulkPath()}}
+		res = append(res, fmt.Sprintf("%s:", s.BulkPath()))
+		res = append(res, "  post:")
+		res = append(res, "    tags:")
+		res = append(res, fmt.Sprintf("      - %s", pkg.Package))
+		res = append(res, fmt.Sprintf("    summary: %s", s.Name))
+		res = append(res, "    description: Newline delimited JSON objects or CSV with a header naming the fields.")
+		res = append(res, "    requestBody:")
+		res = append(res, "      content:")
+		res = append(res, "        application/x-ndjson:")
+		res = append(res, "          schema:")
+		res = append(res, indentSchema(pkg.Validators.apiSchema(&svc, pkg.Enums.apiSchema(httpmetadata.ApiParameters(&svc), pkg.Enums.serviceTypes(&svc))), "      schema:", 12)...)
+		res = append(res, "        text/csv:")
+		res = append(res, "          schema:")
+		res = append(res, "            type: string")
+		res = append(res, "    responses:")
+		res = append(res, "      \"200\":")
+		res = append(res, "        description: OK")
+		res = append(res, "        content:")
+		res = append(res, "          application/json:")
+		res = append(res, "            schema:")
+		res = append(res, "              type: object")
+		res = append(res, "              properties:")
+		res = append(res, "                rows_affected:")
+		res = append(res, "                  type: integer")
+		res = append(res, "                  format: int64")
+		res = append(res, "      \"default\":")
+		res = append(res, "        description: Error message")
+		res = append(res, "        content:")
+		res = append(res, "          text/plain:")
+		res = append(res, "            schema:")
+		res = append(res, "              type: string")
+	}
+	return res
+}
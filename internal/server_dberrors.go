@@ -0,0 +1,49 @@
+package golang
+
+import (
+	"io/fs"
+
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// dbErrors is the data of the dberrors package templates. The kind.go
+// template, shared by the server types, classifies the errors of the database
+// driver, and the dberrors.go template of each server type translates the
+// kinds to the status codes of the server.
+type dbErrors struct {
+	*metadata.Definition
+	// Driver is the driver whose errors are classified: pgx, libpq, mysql,
+	// sqlite (modernc.org/sqlite) or sqlite3 (github.com/mattn/go-sqlite3).
+	Driver string
+}
+
+// dbErrorsDriver returns the driver opened by the generated main for the
+// engine and the sql_package. The postgresql errors of database/sql are
+// classified by their SQLSTATE, implemented by both pgx/stdlib and lib/pq.
+func dbErrorsDriver(def *metadata.Definition) string {
+	switch def.Database() {
+	case "postgresql":
+		if parseDriver(def.SqlPackage()).IsPGX() {
+			return "pgx"
+		}
+		return "libpq"
+	case "mysql":
+		return "mysql"
+	case "sqlite":
+		if def.LiteFS || def.Litestream {
+			return "sqlite3"
+		}
+		return "sqlite"
+	}
+	return ""
+}
+
+// dbErrorsTemplatesFS adds the classification of the database errors to the
+// dberrors package of the server type.
+func dbErrorsTemplatesFS(base fs.FS) (fs.FS, error) {
+	top, err := fs.Sub(serverTemplates, "server_templates/dberrors")
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: top, base: base}, nil
+}
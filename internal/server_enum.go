@@ -0,0 +1,386 @@
+package golang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// serverEnum is a sql enum exposed by the server. The grpc and connect servers
+// declare it as a proto enum, whose zero value is UNSPECIFIED, and the http
+// server describes its values in the OpenAPI. The Null<Name> type of the enum
+// is bound to the same proto enum, UNSPECIFIED meaning NULL.
+type serverEnum struct {
+	Name   string
+	Values []serverEnumValue
+}
+
+type serverEnumValue struct {
+	// Constant is the Go constant of the value generated by sqlc.
+	Constant string
+	// Proto is the name of the value in the proto enum.
+	Proto string
+	// Value is the value of the sql enum.
+	Value string
+}
+
+// Unspecified returns the name of the zero value of the proto enum.
+func (e *serverEnum) Unspecified() string {
+	return protoEnumPrefix(e.Name) + "_UNSPECIFIED"
+}
+
+func protoEnumPrefix(name string) string {
+	return strings.ToUpper(converter.ToSnakeCase(name))
+}
+
+func toServerEnum(enum Enum) *serverEnum {
+	e := serverEnum{Name: enum.Name}
+	prefix := protoEnumPrefix(enum.Name)
+	seen := map[string]struct{}{e.Unspecified(): {}}
+	for i, c := range enum.Constants {
+		// the proto values are prefixed by the enum, as they share the scope
+		// of the proto package
+		proto := prefix + "_" + strings.ToUpper(converter.ToSnakeCase(strings.TrimPrefix(c.Name, enum.Name)))
+		if _, found := seen[proto]; found || proto == prefix+"_" {
+			proto = fmt.Sprintf("%s_VALUE_%d", prefix, i)
+		}
+		seen[proto] = struct{}{}
+		e.Values = append(e.Values, serverEnumValue{
+			Constant: c.Name,
+			Proto:    proto,
+			Value:    c.Value,
+		})
+	}
+	return &e
+}
+
+// serverEnums are the enums of the package, referenced by the Go types of the
+// fields: <Name>, Null<Name> and []<Name>.
+type serverEnums []*serverEnum
+
+// lookup returns the enum of the type and if the type is the Null<Name> type
+// of the enum.
+func (enums serverEnums) lookup(typ string) (*serverEnum, bool, bool) {
+	typ = strings.TrimPrefix(typ, "[]")
+	for _, e := range enums {
+		switch typ {
+		case e.Name:
+			return e, false, true
+		case "Null" + e.Name:
+			return e, true, true
+		}
+	}
+	return nil, false, false
+}
+
+// adapter returns the function converting the Go value of the type to the
+// proto enum.
+func (enums serverEnums) adapter(typ string) (string, bool) {
+	e, null, ok := enums.lookup(typ)
+	if !ok {
+		return "", false
+	}
+	if null {
+		return "toNull" + e.Name, true
+	}
+	return "to" + e.Name, true
+}
+
+// protoEnums returns the declarations of the proto enums.
+func (enums serverEnums) protoEnums() []string {
+	res := make([]string, 0)
+	for _, e := range enums {
+		res = append(res, "")
+		res = append(res, fmt.Sprintf("enum %s {", e.Name))
+		res = append(res, fmt.Sprintf("    %s = 0;", e.Unspecified()))
+		for i, v := range e.Values {
+			res = append(res, fmt.Sprintf("    %s = %d;", v.Proto, i+1))
+		}
+		res = append(res, "}")
+	}
+	return res
+}
+
+// protoMessages returns the messages with the Null<Name> fields typed as the
+// proto enum, using the "Type.ElementType" notation of the aliases.
+func (enums serverEnums) protoMessages(messages map[string]*metadata.Message) map[string]*metadata.Message {
+	res := make(map[string]*metadata.Message, len(messages))
+	for k, m := range messages {
+		msg := *m
+		msg.Fields = make([]*metadata.Field, len(m.Fields))
+		for i, f := range m.Fields {
+			field := *f
+			if e, null, ok := enums.lookup(f.Type); ok && null {
+				field.Type = f.Type + "." + e.Name
+			}
+			msg.Fields[i] = &field
+		}
+		res[k] = &msg
+	}
+	return res
+}
+
+// bindToGo converts the proto enums of the request to the Go values, falling
+// back to converter.BindToGo for the other types.
+func (enums serverEnums) bindToGo(src, dst, attrName, attrType string, newVar bool) []string {
+	e, null, ok := enums.lookup(attrType)
+	if !ok {
+		return converter.BindToGo(src, dst, attrName, attrType, newVar)
+	}
+	fn := "from" + e.Name
+	if null {
+		fn = "fromNull" + e.Name
+	}
+	res := make([]string, 0)
+	if newVar {
+		res = append(res, fmt.Sprintf("var %s %s", dst, attrType))
+	}
+	if strings.HasPrefix(attrType, "[]") {
+		res = append(res, fmt.Sprintf("for _, item := range %s.Get%s() {", src, converter.CamelCaseProto(attrName)))
+		res = append(res, fmt.Sprintf("if v, err := %s(item); err != nil {", fn))
+		res = append(res, fmt.Sprintf("err = fmt.Errorf(\"invalid %s: %%s%%w\", err.Error(), validation.ErrUserInput)", attrName))
+		res = append(res, fmt.Sprintf("return nil, err } else { %s = append(%s, v) }", dst, dst))
+		res = append(res, "}")
+		return res
+	}
+	res = append(res, fmt.Sprintf("if v, err := %s(%s.Get%s()); err != nil {", fn, src, converter.CamelCaseProto(attrName)))
+	res = append(res, fmt.Sprintf("err = fmt.Errorf(\"invalid %s: %%s%%w\", err.Error(), validation.ErrUserInput)", attrName))
+	res = append(res, fmt.Sprintf("return nil, err } else { %s = v }", dst))
+	return res
+}
+
+// bindToProto converts the Go values to the proto enums of the response,
+// falling back to converter.BindToProto for the other types.
+func (enums serverEnums) bindToProto(src, dst, attrName, attrType string) []string {
+	fn, ok := enums.adapter(attrType)
+	if !ok {
+		return converter.BindToProto(src, dst, attrName, attrType)
+	}
+	if strings.HasPrefix(attrType, "[]") {
+		return []string{
+			fmt.Sprintf("for _, v := range %s.%s {", src, attrName),
+			fmt.Sprintf("%s.%s = append(%s.%s, %s(v))", dst, converter.CamelCaseProto(attrName), dst, converter.CamelCaseProto(attrName), fn),
+			"}",
+		}
+	}
+	return []string{fmt.Sprintf("%s.%s = %s(%s.%s)", dst, converter.CamelCaseProto(attrName), fn, src, attrName)}
+}
+
+// adapterToProto replaces the AdapterToProto method of the messages in the
+// output adapters.
+func (enums serverEnums) adapterToProto(m *metadata.Message, src, dst string) []string {
+	res := make([]string, 0)
+	for _, f := range m.Fields {
+		res = append(res, enums.bindToProto(src, dst, converter.UpperFirstCharacter(f.Name), f.Type)...)
+	}
+	return res
+}
+
+// inputGrpc is metadata.InputGrpc converting the proto enums of the request,
+// read from src. It's shared by the grpc and connect servers.
+func (enums serverEnums) inputGrpc(s *metadata.Service, src string) []string {
+	res := make([]string, 0)
+	if s.EmptyInput() {
+		return res
+	}
+
+	if s.HasCustomParams() {
+		typ := s.InputTypes[0]
+		in := s.InputNames[0]
+		if strings.HasPrefix(typ, "*") {
+			res = append(res, fmt.Sprintf("%s := new(%s)", in, typ[1:]))
+		} else {
+			res = append(res, fmt.Sprintf("var %s %s", in, typ))
+		}
+		m := s.Messages[converter.CanonicalName(typ)]
+		for _, f := range m.Fields {
+			attrName := converter.UpperFirstCharacter(f.Name)
+			res = append(res, enums.bindToGo(src, fmt.Sprintf("%s.%s", in, attrName), attrName, f.Type, false)...)
+		}
+	} else {
+		for i, n := range s.InputNames {
+			res = append(res, enums.bindToGo(src, n, converter.UpperFirstCharacter(n), s.InputTypes[i], true)...)
+		}
+	}
+
+	return res
+}
+
+// outputGrpc returns the response of a service returning an enum, wrapped by
+// the response function of the server.
+func (enums serverEnums) outputGrpc(s *metadata.Service, response func(string) string) ([]string, bool) {
+	fn, ok := enums.adapter(s.Output)
+	if !ok || s.HasArrayOutput() {
+		// the lists are converted by the to<Name> adapters
+		return nil, false
+	}
+	return []string{fmt.Sprintf("return %s, nil", response(fmt.Sprintf("&pb.%sResponse{Value: %s(result)}", converter.UpperFirstCharacter(s.Name), fn)))}, true
+}
+
+// inputHttp converts the query and path parameters of the request to the
+// enums. The other fields of the request are decoded as the Go types.
+func (enums serverEnums) inputHttp(s *metadata.Service, lines []string) []string {
+	if len(enums) == 0 {
+		return lines
+	}
+	replaces := make(map[string][]string)
+	bind := func(attrName, typ string) {
+		e, null, ok := enums.lookup(typ)
+		if !ok || strings.HasPrefix(typ, "[]") {
+			return
+		}
+		for _, src := range []string{"r.PathValue", "r.URL.Query().Get"} {
+			bound := httpmetadata.BindStringToSerializable(src, "req", attrName, typ)
+			if len(bound) != 1 {
+				continue
+			}
+			if null {
+				replaces[bound[0]] = []string{
+					fmt.Sprintf("if str := %s(\"%s\"); str != \"\" {", src, converter.ToSnakeCase(attrName)),
+					fmt.Sprintf("req.%s = %s{%s: %s(str), Valid: true}", attrName, typ, e.Name, e.Name),
+					"}",
+				}
+				continue
+			}
+			replaces[bound[0]] = []string{fmt.Sprintf("req.%s = %s(%s(\"%s\"))", attrName, typ, src, converter.ToSnakeCase(attrName))}
+		}
+	}
+	for i, typ := range s.InputTypes {
+		if m, ok := s.Messages[converter.CanonicalName(typ)]; ok {
+			for _, f := range m.Fields {
+				bind(converter.UpperFirstCharacter(f.Name), f.Type)
+			}
+			continue
+		}
+		bind(converter.UpperFirstCharacter(s.InputNames[i]), typ)
+	}
+	if len(replaces) == 0 {
+		return lines
+	}
+	res := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if r, ok := replaces[line]; ok {
+			res = append(res, r...)
+			continue
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+// serviceTypes returns the Go types of the fields of the request and response
+// of the service, by the names used in the OpenAPI.
+func (enums serverEnums) serviceTypes(s *metadata.Service) map[string]string {
+	res := make(map[string]string)
+	for i, typ := range s.InputTypes {
+		if m, ok := s.Messages[converter.CanonicalName(typ)]; ok {
+			for _, f := range m.Fields {
+				res[converter.ToSnakeCase(converter.CanonicalName(f.Name))] = f.Type
+			}
+			continue
+		}
+		res[converter.ToSnakeCase(converter.CanonicalName(s.InputNames[i]))] = typ
+	}
+	if m, ok := s.Messages[converter.CanonicalName(s.Output)]; ok {
+		for _, f := range m.Fields {
+			res[converter.ToSnakeCase(converter.CanonicalName(f.Name))] = f.Type
+		}
+	}
+	return res
+}
+
+// apiSchema adds the values of the enums to the OpenAPI schemas described by
+// the lines. The enum fields are identified by the property or parameter name
+// preceding the type of the schema.
+func (enums serverEnums) apiSchema(lines []string, types map[string]string) []string {
+	if len(enums) == 0 {
+		return lines
+	}
+	res := make([]string, 0, len(lines))
+	var pending *serverEnum
+	for _, line := range lines {
+		res = append(res, line)
+		trimmed := strings.TrimSpace(line)
+		name, isParam := strings.CutPrefix(trimmed, "- name: ")
+		if isParam || (strings.HasSuffix(trimmed, ":") && !strings.ContainsAny(trimmed, " \"")) {
+			name = strings.TrimSuffix(name, ":")
+			if typ, ok := types[name]; ok {
+				pending, _, _ = enums.lookup(typ)
+			}
+			continue
+		}
+		if pending != nil && strings.HasPrefix(trimmed, "type: ") {
+			res = append(res, enumApiValues(pending, line[:len(line)-len(strings.TrimLeft(line, " "))])...)
+			pending = nil
+		}
+	}
+	return res
+}
+
+// apiResponse adds the values of the enums to the OpenAPI response of the
+// service.
+func (enums serverEnums) apiResponse(s *metadata.Service, lines []string) []string {
+	if e, _, ok := enums.lookup(s.Output); ok && s.HasArrayOutput() {
+		return enums.apiSchema(lines, map[string]string{"items": e.Name})
+	}
+	return enums.apiSchema(lines, enums.serviceTypes(s))
+}
+
+// apiComponentSchemas adds the values of the enums to the OpenAPI schemas of
+// the messages.
+func (enums serverEnums) apiComponentSchemas(messages map[string]*metadata.Message, lines []string) []string {
+	if len(enums) == 0 {
+		return lines
+	}
+	res := make([]string, 0, len(lines))
+	var (
+		schema []string
+		types  map[string]string
+	)
+	flush := func() {
+		res = append(res, enums.apiSchema(schema, types)...)
+		schema = nil
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, " ") {
+			flush()
+			types = make(map[string]string)
+			for _, m := range messages {
+				if m.PackageName+m.Name+":" != line {
+					continue
+				}
+				for _, f := range m.Fields {
+					types[converter.ToSnakeCase(converter.CanonicalName(f.Name))] = f.Type
+				}
+			}
+		}
+		schema = append(schema, line)
+	}
+	flush()
+	return res
+}
+
+func enumApiValues(e *serverEnum, indent string) []string {
+	res := []string{indent + "enum:"}
+	for _, v := range e.Values {
+		res = append(res, fmt.Sprintf("%s  - %s", indent, strconv.Quote(v.Value)))
+	}
+	return res
+}
+
+// protoPackage returns the package described by the proto, with the
+// Null<Name> fields typed as the proto enums.
+func (pkg *serverPackage) protoPackage() *serverPackage {
+	if len(pkg.Enums) == 0 {
+		return pkg
+	}
+	protoPkg := *pkg.metadataPackage
+	protoPkg.Messages = pkg.Enums.protoMessages(pkg.Messages)
+	res := *pkg
+	res.metadataPackage = &protoPkg
+	return &res
+}
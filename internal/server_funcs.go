@@ -0,0 +1,208 @@
+package golang
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// execCountFieldName returns the response field used to expose the int64
+// returned by the generated Queries method for :execrows, :execlastid and
+// :copyfrom.
+func execCountFieldName(cmd string) (string, bool) {
+	switch cmd {
+	case ":execrows", ":copyfrom":
+		return "RowsAffected", true
+	case ":execlastid":
+		return "LastInsertId", true
+	}
+	return "", false
+}
+
+func execCountField(s *metadata.Service) (*metadata.Field, bool) {
+	if s.Output != "int64" {
+		return nil, false
+	}
+	m, ok := s.Messages[s.Name+"Response"]
+	if !ok || len(m.Fields) != 1 {
+		return nil, false
+	}
+	switch f := m.Fields[0]; f.Name {
+	case "RowsAffected", "LastInsertId":
+		return f, true
+	}
+	return nil, false
+}
+
+// listOutputGrpc returns the response of a :many query returning a single
+// column, whose rows aren't converted by a to<Name> adapter like the structs
+// and the enums.
+func listOutputGrpc(s *metadata.Service, enums serverEnums, response func(string) string) ([]string, bool) {
+	if !s.HasArrayOutput() {
+		return nil, false
+	}
+	typ := strings.TrimPrefix(s.Output, "[]")
+	if _, ok := s.Messages[converter.CanonicalName(typ)]; ok {
+		return nil, false
+	}
+	if _, ok := enums.adapter(typ); ok {
+		return nil, false
+	}
+	return []string{fmt.Sprintf("return %s, nil", response(fmt.Sprintf("&pb.%sResponse{List: result}", converter.UpperFirstCharacter(s.Name))))}, true
+}
+
+func grpcFuncs(funcs template.FuncMap, enums serverEnums, validators serverValidators) template.FuncMap {
+	res := maps.Clone(funcs)
+	output := funcs["Output"].(func(*metadata.Service) []string)
+	res["Input"] = func(s *metadata.Service) []string {
+		return append(enums.inputGrpc(s, "req"), validators.inputGrpc(s)...)
+	}
+	res["Output"] = func(s *metadata.Service) []string {
+		if f, ok := execCountField(s); ok {
+			return []string{fmt.Sprintf("return &pb.%sResponse{%s: result}, nil", converter.UpperFirstCharacter(s.Name), f.Name)}
+		}
+		if lines, ok := enums.outputGrpc(s, func(res string) string { return res }); ok {
+			return lines
+		}
+		if lines, ok := listOutputGrpc(s, enums, func(res string) string { return res }); ok {
+			return lines
+		}
+		return output(s)
+	}
+	res["AdapterToProto"] = enums.adapterToProto
+	res["BatchInput"] = func(s *batchService) []string { return batchInputGrpc(s, enums, validators) }
+	res["BatchResult"] = func(s *batchService) []string { return batchResultGrpc(s, enums) }
+	res["CopyFromInput"] = func(s *copyFromService) []string { return copyFromInputGrpc(s, enums, validators) }
+	res["CopyFromDecode"] = copyFromDecodeGrpc
+	res["StreamInput"] = func(s *streamService) []string { return streamInputGrpc(s, enums, validators) }
+	res["StreamSend"] = func(s *streamService) []string { return streamSendGrpc(s, enums) }
+	res["PageInput"] = func(s *pageService) []string { return pageInputGrpc(s, enums, validators) }
+	res["PageOutput"] = func(s *pageService) []string { return pageOutputGrpc(s, enums) }
+	res["TxInput"] = func(s *transactionStep) []string { return transactionInputGrpc(s, enums) }
+	res["TxCall"] = func(s *transactionStep) []string {
+		return transactionCall(s, "ctx", validators, "return validation.InvalidArgument(err)", func(s *transactionStep, src string, assign func(string) []string) []string {
+			return transactionResultGrpc(s, enums, src, assign)
+		})
+	}
+	return res
+}
+
+func connectFuncs(funcs template.FuncMap, enums serverEnums, validators serverValidators) template.FuncMap {
+	res := maps.Clone(funcs)
+	output := funcs["Output"].(func(*metadata.Service) []string)
+	res["Input"] = func(s *metadata.Service) []string {
+		return append(enums.inputGrpc(s, "req.Msg"), validators.inputGrpc(s)...)
+	}
+	res["Output"] = func(s *metadata.Service) []string {
+		if f, ok := execCountField(s); ok {
+			return []string{fmt.Sprintf("return connect.NewResponse(&pb.%sResponse{%s: result}), nil", converter.UpperFirstCharacter(s.Name), f.Name)}
+		}
+		if lines, ok := enums.outputGrpc(s, func(res string) string { return "connect.NewResponse(" + res + ")" }); ok {
+			return lines
+		}
+		if lines, ok := listOutputGrpc(s, enums, func(res string) string { return "connect.NewResponse(" + res + ")" }); ok {
+			return lines
+		}
+		return output(s)
+	}
+	res["AdapterToProto"] = enums.adapterToProto
+	res["BatchInput"] = func(s *batchService) []string { return batchInputGrpc(s, enums, validators) }
+	res["BatchResult"] = func(s *batchService) []string { return batchResultGrpc(s, enums) }
+	res["CopyFromInput"] = func(s *copyFromService) []string { return copyFromInputGrpc(s, enums, validators) }
+	res["CopyFromDecode"] = copyFromDecodeGrpc
+	res["StreamInput"] = func(s *streamService) []string { return streamInputGrpc(s, enums, validators) }
+	res["StreamSend"] = func(s *streamService) []string { return streamSendGrpc(s, enums) }
+	res["PageInput"] = func(s *pageService) []string { return pageInputGrpc(s, enums, validators) }
+	res["PageOutput"] = func(s *pageService) []string { return pageOutputGrpc(s, enums) }
+	res["TxInput"] = func(s *transactionStep) []string { return transactionInputGrpc(s, enums) }
+	res["TxCall"] = func(s *transactionStep) []string {
+		return transactionCall(s, "ctx", validators, "return validation.InvalidArgument(err)", func(s *transactionStep, src string, assign func(string) []string) []string {
+			return transactionResultGrpc(s, enums, src, assign)
+		})
+	}
+	return res
+}
+
+func httpFuncs(funcs template.FuncMap, enums serverEnums, validators serverValidators) template.FuncMap {
+	res := maps.Clone(funcs)
+	input := funcs["Input"].(func(*metadata.Service) []string)
+	res["Input"] = func(s *metadata.Service) []string {
+		return append(enums.inputHttp(s, input(s)), validators.inputHttp(s)...)
+	}
+	handlerTypes := funcs["HandlerTypes"].(func(*metadata.Service) []string)
+	res["HandlerTypes"] = func(s *metadata.Service) []string {
+		lines := handlerTypes(s)
+		if f, ok := execCountField(s); ok {
+			lines = append(lines, "type response struct {")
+			lines = append(lines, fmt.Sprintf("%s int64 `json:\"%s\"`", f.Name, converter.ToSnakeCase(f.Name)))
+			lines = append(lines, "}")
+		}
+		return lines
+	}
+	output := funcs["Output"].(func(*metadata.Service) []string)
+	res["Output"] = func(s *metadata.Service) []string {
+		if f, ok := execCountField(s); ok {
+			return []string{fmt.Sprintf("server.Encode(w, r, http.StatusOK, response{%s: result})", f.Name)}
+		}
+		return output(s)
+	}
+	apiParameters := funcs["ApiParameters"].(func(*metadata.Service) []string)
+	res["ApiParameters"] = func(s *metadata.Service) []string {
+		return validators.apiSchema(s, enums.apiSchema(apiParameters(s), enums.serviceTypes(s)))
+	}
+	apiComponentSchemas := funcs["ApiComponentSchemas"].(func(*metadata.Package) []string)
+	res["ApiComponentSchemas"] = func(pkg *metadata.Package) []string {
+		return enums.apiComponentSchemas(pkg.Messages, apiComponentSchemas(pkg))
+	}
+	apiResponse := funcs["ApiResponse"].(func(*metadata.Service) []string)
+	enumApiResponse := func(s *metadata.Service) []string {
+		return enums.apiResponse(s, apiResponse(s))
+	}
+	res["ApiResponse"] = func(s *metadata.Service) []string {
+		if isStream(s) {
+			return streamApiResponse(enumApiResponse, s)
+		}
+		if isPage(s) {
+			return pageApiResponse(enumApiResponse, s)
+		}
+		f, ok := execCountField(s)
+		if !ok {
+			return enumApiResponse(s)
+		}
+		return []string{
+			"content:",
+			"  application/json:",
+			"    schema:",
+			"      type: object",
+			"      properties:",
+			fmt.Sprintf("        %s:", converter.ToSnakeCase(f.Name)),
+			"          type: integer",
+			"          format: int64",
+		}
+	}
+	res["BatchHandlerTypes"] = batchHandlerTypes
+	res["BatchInput"] = func(s *batchService) []string {
+		return append(batchInputHttp(s), validators.inputHttp(s.Service)...)
+	}
+	res["BatchResult"] = batchResultHttp
+	res["CopyFromHandlerTypes"] = copyFromHandlerTypes
+	res["CopyFromInput"] = func(s *copyFromService) []string {
+		return append(copyFromInputHttp(s), validators.inputHttp(s.Service)...)
+	}
+	res["StreamOutput"] = streamOutputHttp
+	res["PageHandlerTypes"] = pageHandlerTypes
+	res["PageInput"] = func(s *pageService) []string {
+		return append(enums.inputHttp(s.Service, pageInputHttp(s)), validators.inputHttp(s.Service)...)
+	}
+	res["PageOutput"] = pageOutputHttp
+	res["TxHandlerTypes"] = transactionHandlerTypes
+	res["TxInput"] = transactionInputHttp
+	res["TxCall"] = func(s *transactionStep) []string {
+		return transactionCall(s, "ctx", validators, "return err", transactionResultHttp)
+	}
+	return res
+}
@@ -0,0 +1,165 @@
+package golang
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+)
+
+// goVersion is the minimal Go version of the generated module. The http
+// server relies on the method and wildcard patterns of net/http.ServeMux.
+const goVersion = "1.22"
+
+// dependencies pins the versions of the modules imported by the generated
+// code, including the drivers and types imported by the queries. Modules not
+// listed here are listed by a comment of the go.mod, and they and the
+// indirect requirements are left to be resolved by go mod tidy.
+var dependencies = map[string]string{
+	"connectrpc.com/connect":                                            "v1.16.0",
+	"connectrpc.com/grpcreflect":                                        "v1.2.0",
+	"connectrpc.com/otelconnect":                                        "v0.7.0",
+	"github.com/XSAM/otelsql":                                           "v0.29.0",
+	"github.com/benbjohnson/litestream":                                 "v0.3.13",
+	"github.com/bufbuild/buf":                                           "v1.30.0",
+	"github.com/exaring/otelpgx":                                        "v0.5.2",
+	"github.com/flowchartsman/swaggerui":                                "v0.0.0-20221017034628-909ed4f3701b",
+	"github.com/go-playground/form/v4":                                  "v4.2.1",
+	"github.com/go-sql-driver/mysql":                                    "v1.8.0",
+	"github.com/golang-migrate/migrate/v4":                              "v4.17.0",
+	"github.com/google/uuid":                                            "v1.6.0",
+	"github.com/graph-gophers/graphql-go":                               "v1.5.0",
+	"github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus": "v1.0.0",
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2":                   "v2.1.0",
+	"github.com/grpc-ecosystem/grpc-gateway/v2":                         "v2.19.1",
+	"github.com/hashicorp/raft":                                         "v1.6.1",
+	"github.com/hexon/mysqltsv":                                         "v0.1.0",
+	"github.com/jackc/pgx/v5":                                           "v5.5.5",
+	"github.com/lib/pq":                                                 "v1.10.9",
+	"github.com/mattn/go-sqlite3":                                       "v1.14.22",
+	"github.com/pgvector/pgvector-go":                                   "v0.1.1",
+	"github.com/pressly/goose/v3":                                       "v3.19.2",
+	"github.com/prometheus/client_golang":                               "v1.19.0",
+	"github.com/sqlc-dev/pqtype":                                        "v0.3.0",
+	"github.com/superfly/litefs":                                        "v0.5.11",
+	"github.com/twitchtv/twirp":                                         "v8.1.3+incompatible",
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc": "v0.49.0",
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp":               "v0.49.0",
+	"go.opentelemetry.io/otel": "v1.24.0",
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc": "v1.24.0",
+	"go.opentelemetry.io/otel/exporters/prometheus":                   "v0.46.0",
+	"go.opentelemetry.io/otel/sdk":                                    "v1.24.0",
+	"go.opentelemetry.io/otel/sdk/metric":                             "v1.24.0",
+	"go.opentelemetry.io/otel/trace":                                  "v1.24.0",
+	"go.uber.org/automaxprocs":                                        "v1.5.3",
+	"golang.org/x/net":                                                "v0.22.0",
+	"google.golang.org/genproto/googleapis/rpc":                       "v0.0.0-20240125205218-1f4bbc51befe",
+	"google.golang.org/grpc":                                          "v1.62.1",
+	"google.golang.org/grpc/cmd/protoc-gen-go-grpc":                   "v1.3.0",
+	"google.golang.org/protobuf":                                      "v1.33.0",
+	"modernc.org/sqlite":                                              "v1.29.5",
+}
+
+// protoDependencies are the modules imported by the code generated by buf
+// from the proto files.
+var protoDependencies = map[string][]string{
+	"grpc": {
+		"github.com/grpc-ecosystem/grpc-gateway/v2",
+		"google.golang.org/grpc",
+		"google.golang.org/protobuf",
+	},
+	"connect": {
+		"connectrpc.com/connect",
+		"google.golang.org/protobuf",
+	},
+	"twirp": {
+		"github.com/twitchtv/twirp",
+		"google.golang.org/protobuf",
+	},
+}
+
+func goModFile(module, serverType string, files []*plugin.File) ([]byte, error) {
+	required := make(map[string]struct{})
+	unknown := make(map[string]struct{})
+	for _, m := range protoDependencies[serverType] {
+		required[m] = struct{}{}
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file.Name, file.Contents, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range f.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			if m, ok := dependencyModule(importPath); ok {
+				required[m] = struct{}{}
+			} else if isModuleImport(module, importPath) {
+				unknown[importPath] = struct{}{}
+			}
+		}
+	}
+	modules := make([]string, 0, len(required))
+	for m := range required {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "module %s\n\ngo %s\n", module, goVersion)
+	if len(modules) > 0 {
+		b.WriteString("\nrequire (\n")
+		for _, m := range modules {
+			fmt.Fprintf(&b, "\t%s %s\n", m, dependencies[m])
+		}
+		b.WriteString(")\n")
+	}
+	if len(unknown) > 0 {
+		imports := make([]string, 0, len(unknown))
+		for importPath := range unknown {
+			imports = append(imports, importPath)
+		}
+		sort.Strings(imports)
+		b.WriteString("\n// The modules of these imports aren't pinned by the plugin, run go mod tidy\n// to require them:\n")
+		for _, importPath := range imports {
+			fmt.Fprintf(&b, "//\t%s\n", importPath)
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// isModuleImport reports if the import path is a package of another module,
+// not of the standard library nor of the generated module.
+func isModuleImport(module, importPath string) bool {
+	if importPath == module || strings.HasPrefix(importPath, module+"/") {
+		return false
+	}
+	first, _, _ := strings.Cut(importPath, "/")
+	return strings.Contains(first, ".")
+}
+
+// dependencyModule returns the module of the dependencies table providing
+// the package of the import path.
+func dependencyModule(importPath string) (string, bool) {
+	p := importPath
+	for {
+		if _, ok := dependencies[p]; ok {
+			return p, true
+		}
+		i := strings.LastIndex(p, "/")
+		if i == -1 {
+			return "", false
+		}
+		p = p[:i]
+	}
+}
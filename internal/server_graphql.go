@@ -0,0 +1,751 @@
+package golang
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// graphqlBaseTemplates returns the templates of sqlc-http shared by the
+// graphql server: the main and database files, the instrumentation,
+// LiteFS, Litestream and migrations. The REST routes, the OpenAPI and the
+// JSON encoding aren't generated.
+func graphqlBaseTemplates(httpFS fs.FS) fs.FS {
+	return excludeFS{
+		base:  httpFS,
+		names: []string{"routes.go.tmpl", "openapi.yml.tmpl", "internal/server/encoding.go.tmpl"},
+	}
+}
+
+func graphqlFuncs(funcs template.FuncMap, enums serverEnums, validators serverValidators) template.FuncMap {
+	res := maps.Clone(funcs)
+	res["GraphqlSchema"] = func(pkg *serverPackage) []string { return toGraphqlSchema(pkg, enums, validators).sdl() }
+	res["GraphqlResolvers"] = func(pkg *serverPackage) []string { return toGraphqlSchema(pkg, enums, validators).resolvers(false) }
+	res["GraphqlPageResolvers"] = func(pkg *serverPackage) []string { return toGraphqlSchema(pkg, enums, validators).resolvers(true) }
+	res["GraphqlAdapters"] = func(pkg *serverPackage) []string { return toGraphqlSchema(pkg, enums, validators).adapters() }
+	return res
+}
+
+// graphqlSchema is the GraphQL schema of a package. The queries are the
+// fields of the Query and Mutation types, resolved by the methods of the
+// Service, and the results are objects converted from the structs
+// generated by sqlc.
+type graphqlSchema struct {
+	pkg        *serverPackage
+	enums      serverEnums
+	validators serverValidators
+	fields     []*graphqlField
+	objects    []*graphqlObject
+	// usedEnums and scalars are the enums and custom scalars referenced by
+	// the schema.
+	usedEnums []*serverEnum
+	scalars   map[string]struct{}
+}
+
+// graphqlField is a query exposed as a field of the Query or Mutation type.
+type graphqlField struct {
+	svc      *metadata.Service
+	page     *pageService
+	mutation bool
+	args     []*graphqlValue
+	result   graphqlType
+}
+
+// graphqlValue is an argument of a field, or a field of an object.
+type graphqlValue struct {
+	// Name is the GraphQL name.
+	Name string
+	// GoName is the name of the field in the args struct of the resolver, or
+	// in the struct generated by sqlc.
+	GoName string
+	Type   graphqlType
+}
+
+// graphqlObject is the GraphQL object of a struct generated by sqlc, or of
+// a page.
+type graphqlObject struct {
+	// Name is the GraphQL type and the name of the struct.
+	Name   string
+	fields []*graphqlValue
+	page   bool
+}
+
+// goType returns the Go type resolving the object.
+func (o *graphqlObject) goType() string {
+	if o.page {
+		return converter.LowerFirstCharacter(o.Name)
+	}
+	return converter.LowerFirstCharacter(o.Name) + "Object"
+}
+
+// graphqlType binds a Go type generated by sqlc to a GraphQL type. The
+// resolvers exchange the values as the Resolver type.
+type graphqlType struct {
+	// Name is the GraphQL type, with the list and non-null markers.
+	Name string
+	// Resolver is the Go type of the values of the resolvers.
+	Resolver string
+	// out returns the lines assigning the sqlc value src to the resolver
+	// value dst.
+	out func(dst, src string) []string
+	// in returns the lines assigning the resolver value src to the sqlc value
+	// dst, returning zero and the error of the conversions.
+	in func(dst, src, zero string) []string
+}
+
+// graphqlScalar is a non-null GraphQL scalar (or enum) bound to a Go type.
+type graphqlScalar struct {
+	name     string
+	resolver string
+	out      func(v string) string
+	in       func(v string) string
+}
+
+func identity(v string) string { return v }
+
+func convert(typ string) func(string) string {
+	return func(v string) string { return typ + "(" + v + ")" }
+}
+
+// graphqlScalars are the Go types of the non-null values bound to the
+// GraphQL scalars. The 64-bit integers and the UUIDs are custom scalars
+// declared by the server package.
+var graphqlScalars = map[string]graphqlScalar{
+	"string":    {name: "String", resolver: "string", out: identity, in: identity},
+	"bool":      {name: "Boolean", resolver: "bool", out: identity, in: identity},
+	"int32":     {name: "Int", resolver: "int32", out: identity, in: identity},
+	"int16":     {name: "Int", resolver: "int32", out: convert("int32"), in: convert("int16")},
+	"uint16":    {name: "Int", resolver: "int32", out: convert("int32"), in: convert("uint16")},
+	"int":       {name: "Int64", resolver: "server.Int64", out: convert("server.Int64"), in: convert("int")},
+	"int64":     {name: "Int64", resolver: "server.Int64", out: convert("server.Int64"), in: convert("int64")},
+	"uint32":    {name: "Int64", resolver: "server.Int64", out: convert("server.Int64"), in: convert("uint32")},
+	"uint64":    {name: "Int64", resolver: "server.Int64", out: convert("server.Int64"), in: convert("uint64")},
+	"float64":   {name: "Float", resolver: "float64", out: identity, in: identity},
+	"float32":   {name: "Float", resolver: "float64", out: convert("float64"), in: convert("float32")},
+	"time.Time": {name: "Time", resolver: "graphql.Time", out: func(v string) string { return "graphql.Time{Time: " + v + "}" }, in: func(v string) string { return strings.TrimPrefix(v, "*") + ".Time" }},
+	"uuid.UUID": {name: "UUID", resolver: "server.UUID", out: convert("server.UUID"), in: convert("uuid.UUID")},
+	// the Bytes of pgtype.UUID
+	"[16]byte": {name: "UUID", resolver: "server.UUID", out: convert("server.UUID"), in: convert("[16]byte")},
+}
+
+// graphqlNullTypes are the Go types of the nullable values, holding the
+// value in a field along with the Valid field.
+var graphqlNullTypes = map[string]struct{ typ, field string }{
+	"sql.NullString":     {"string", "String"},
+	"sql.NullBool":       {"bool", "Bool"},
+	"sql.NullInt16":      {"int16", "Int16"},
+	"sql.NullInt32":      {"int32", "Int32"},
+	"sql.NullInt64":      {"int64", "Int64"},
+	"sql.NullFloat64":    {"float64", "Float64"},
+	"sql.NullTime":       {"time.Time", "Time"},
+	"pgtype.Text":        {"string", "String"},
+	"pgtype.Bool":        {"bool", "Bool"},
+	"pgtype.Int2":        {"int16", "Int16"},
+	"pgtype.Int4":        {"int32", "Int32"},
+	"pgtype.Int8":        {"int64", "Int64"},
+	"pgtype.Float4":      {"float32", "Float32"},
+	"pgtype.Float8":      {"float64", "Float64"},
+	"pgtype.Date":        {"time.Time", "Time"},
+	"pgtype.Timestamp":   {"time.Time", "Time"},
+	"pgtype.Timestamptz": {"time.Time", "Time"},
+	"pgtype.UUID":        {"[16]byte", "Bytes"},
+	"uuid.NullUUID":      {"uuid.UUID", "UUID"},
+}
+
+func toGraphqlSchema(pkg *serverPackage, enums serverEnums, validators serverValidators) *graphqlSchema {
+	s := graphqlSchema{
+		pkg:        pkg,
+		enums:      enums,
+		validators: validators,
+		scalars:    make(map[string]struct{}),
+	}
+	for _, svc := range pkg.Services {
+		s.fields = append(s.fields, s.toField(svc, nil))
+	}
+	for _, svc := range pkg.StreamServices {
+		// the rows of the stream queries are resolved as a list
+		list := *svc.Service
+		list.Output = "[]" + svc.Output
+		s.fields = append(s.fields, s.toField(&list, nil))
+	}
+	for _, svc := range pkg.PageServices {
+		s.fields = append(s.fields, s.toField(svc.Service, svc))
+	}
+	sort.SliceStable(s.fields, func(i, j int) bool {
+		return strings.Compare(s.fields[i].svc.Name, s.fields[j].svc.Name) < 0
+	})
+	sort.SliceStable(s.objects, func(i, j int) bool {
+		return strings.Compare(s.objects[i].Name, s.objects[j].Name) < 0
+	})
+	sort.SliceStable(s.usedEnums, func(i, j int) bool {
+		return strings.Compare(s.usedEnums[i].Name, s.usedEnums[j].Name) < 0
+	})
+	return &s
+}
+
+func (s *graphqlSchema) toField(svc *metadata.Service, page *pageService) *graphqlField {
+	f := graphqlField{
+		svc:      svc,
+		page:     page,
+		mutation: isGraphqlMutation(svc),
+	}
+	if !svc.EmptyInput() {
+		if m, ok := svc.Messages[converter.CanonicalName(svc.InputTypes[0])]; ok && svc.HasCustomParams() {
+			for _, field := range m.Fields {
+				f.args = append(f.args, &graphqlValue{
+					Name:   graphqlName(field.Name),
+					GoName: field.Name,
+					Type:   s.typeOf(field.Type),
+				})
+			}
+		} else {
+			f.args = append(f.args, &graphqlValue{
+				Name:   graphqlName(svc.InputNames[0]),
+				GoName: converter.UpperFirstCharacter(svc.InputNames[0]),
+				Type:   s.typeOf(svc.InputTypes[0]),
+			})
+		}
+	}
+	f.result = s.resultType(svc)
+	if page != nil {
+		obj := graphqlObject{
+			Name: converter.UpperFirstCharacter(svc.Name) + "Page",
+			fields: []*graphqlValue{
+				{Name: "list", GoName: "List", Type: f.result},
+				{Name: "nextPageToken", GoName: "NextPageToken", Type: s.typeOf("string")},
+			},
+			page: true,
+		}
+		s.objects = append(s.objects, &obj)
+		f.result = graphqlType{Name: obj.Name + "!", Resolver: "*" + obj.goType()}
+	}
+	return &f
+}
+
+// isGraphqlMutation reports if the query is a mutation: the :exec queries
+// and the queries modifying the rows, like an INSERT ... RETURNING.
+func isGraphqlMutation(svc *metadata.Service) bool {
+	switch svc.Output {
+	case "", "sql.Result", "pgconn.CommandTag":
+		return true
+	}
+	if _, ok := execCountField(svc); ok {
+		return true
+	}
+	sql := strings.TrimSpace(svc.Sql)
+	for strings.HasPrefix(sql, "--") {
+		_, sql, _ = strings.Cut(sql, "\n")
+		sql = strings.TrimSpace(sql)
+	}
+	verb, _, _ := strings.Cut(sql, " ")
+	switch strings.ToUpper(verb) {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE":
+		return true
+	}
+	return false
+}
+
+// graphqlName returns the GraphQL name of a Go name, in lower camel case.
+func graphqlName(name string) string {
+	return converter.LowerFirstCharacter(converter.CamelCaseProto(name))
+}
+
+// resultType returns the type of the result of the query. The :exec queries
+// return true and the :execrows, :execlastid and :execresult queries return
+// the count.
+func (s *graphqlSchema) resultType(svc *metadata.Service) graphqlType {
+	switch svc.Output {
+	case "":
+		return graphqlType{Name: "Boolean!", Resolver: "bool"}
+	case "sql.Result", "pgconn.CommandTag":
+		s.scalars["Int64"] = struct{}{}
+		return graphqlType{Name: "Int64!", Resolver: "server.Int64"}
+	}
+	typ := svc.Output
+	if _, ok := s.pkg.Messages[converter.CanonicalName(typ)]; ok && s.pkg.EmitResultPointers {
+		// the structs are returned as pointers
+		typ = strings.Replace(typ, converter.CanonicalName(typ), "*"+converter.CanonicalName(typ), 1)
+	}
+	return s.typeOf(typ)
+}
+
+// typeOf returns the GraphQL type of the Go type. The types without a GraphQL
+// representation are exchanged as JSON.
+func (s *graphqlSchema) typeOf(goType string) graphqlType {
+	if goType == "[]byte" || goType == "json.RawMessage" {
+		return graphqlType{
+			Name:     "String",
+			Resolver: "*string",
+			out: func(dst, src string) []string {
+				return []string{
+					fmt.Sprintf("if %s != nil {", src),
+					fmt.Sprintf("value := string(%s)", src),
+					fmt.Sprintf("%s = &value", dst),
+					"}",
+				}
+			},
+			in: func(dst, src, zero string) []string {
+				return []string{
+					fmt.Sprintf("if %s != nil {", src),
+					fmt.Sprintf("%s = %s(*%s)", dst, goType, src),
+					"}",
+				}
+			},
+		}
+	}
+	if elemType, ok := strings.CutPrefix(goType, "[]"); ok {
+		elem := s.typeOf(elemType)
+		return graphqlType{
+			Name:     "[" + elem.Name + "]!",
+			Resolver: "[]" + elem.Resolver,
+			out: func(dst, src string) []string {
+				if elem.Resolver == elemType {
+					return []string{fmt.Sprintf("%s = %s", dst, src)}
+				}
+				res := []string{
+					fmt.Sprintf("%s = make(%s, 0, len(%s))", dst, "[]"+elem.Resolver, src),
+					fmt.Sprintf("for _, v := range %s {", src),
+				}
+				res = append(res, declare("item", elem.Resolver, elem.out("item", "v"))...)
+				return append(res, fmt.Sprintf("%s = append(%s, item)", dst, dst), "}")
+			},
+			in: func(dst, src, zero string) []string {
+				if elem.Resolver == elemType {
+					return []string{fmt.Sprintf("%s = %s", dst, src)}
+				}
+				res := []string{
+					fmt.Sprintf("%s = make(%s, 0, len(%s))", dst, goType, src),
+					fmt.Sprintf("for _, v := range %s {", src),
+				}
+				res = append(res, declare("item", elemType, elem.in("item", "v", zero))...)
+				return append(res, fmt.Sprintf("%s = append(%s, item)", dst, dst), "}")
+			},
+		}
+	}
+	if msg, ok := s.pkg.Messages[strings.TrimPrefix(goType, "*")]; ok {
+		obj := s.object(msg)
+		deref := ""
+		if strings.HasPrefix(goType, "*") {
+			deref = "*"
+		}
+		return graphqlType{
+			Name:     obj.Name + "!",
+			Resolver: "*" + obj.goType(),
+			out: func(dst, src string) []string {
+				return []string{fmt.Sprintf("%s = to%sObject(%s%s)", dst, obj.Name, deref, src)}
+			},
+		}
+	}
+	if elemType, ok := strings.CutPrefix(goType, "*"); ok {
+		if scalar, ok := s.scalar(elemType); ok {
+			return graphqlType{
+				Name:     scalar.name,
+				Resolver: "*" + scalar.resolver,
+				out: func(dst, src string) []string {
+					return []string{
+						fmt.Sprintf("if %s != nil {", src),
+						fmt.Sprintf("value := %s", scalar.out("*"+src)),
+						fmt.Sprintf("%s = &value", dst),
+						"}",
+					}
+				},
+				in: func(dst, src, zero string) []string {
+					return []string{
+						fmt.Sprintf("if %s != nil {", src),
+						fmt.Sprintf("value := %s", scalar.in("*"+src)),
+						fmt.Sprintf("%s = &value", dst),
+						"}",
+					}
+				},
+			}
+		}
+	}
+	null, ok := graphqlNullTypes[goType]
+	if e, isNull, isEnum := s.enums.lookup(goType); isEnum && isNull {
+		null, ok = struct{ typ, field string }{e.Name, e.Name}, true
+	}
+	if ok {
+		if scalar, found := s.scalar(null.typ); found {
+			return graphqlType{
+				Name:     scalar.name,
+				Resolver: "*" + scalar.resolver,
+				out: func(dst, src string) []string {
+					return []string{
+						fmt.Sprintf("if %s.Valid {", src),
+						fmt.Sprintf("value := %s", scalar.out(src+"."+null.field)),
+						fmt.Sprintf("%s = &value", dst),
+						"}",
+					}
+				},
+				in: func(dst, src, zero string) []string {
+					return []string{
+						fmt.Sprintf("if %s != nil {", src),
+						fmt.Sprintf("%s = %s{%s: %s, Valid: true}", dst, goType, null.field, scalar.in("*"+src)),
+						"}",
+					}
+				},
+			}
+		}
+	}
+	if scalar, ok := s.scalar(goType); ok {
+		return graphqlType{
+			Name:     scalar.name + "!",
+			Resolver: scalar.resolver,
+			out: func(dst, src string) []string {
+				return []string{fmt.Sprintf("%s = %s", dst, scalar.out(src))}
+			},
+			in: func(dst, src, zero string) []string {
+				return []string{fmt.Sprintf("%s = %s", dst, scalar.in(src))}
+			},
+		}
+	}
+	s.scalars["JSON"] = struct{}{}
+	return graphqlType{
+		Name:     "JSON",
+		Resolver: "*server.JSON",
+		out: func(dst, src string) []string {
+			return []string{fmt.Sprintf("%s = &server.JSON{Value: %s}", dst, src)}
+		},
+		in: func(dst, src, zero string) []string {
+			return []string{
+				fmt.Sprintf("if %s != nil {", src),
+				fmt.Sprintf("if err := %s.Decode(&%s); err != nil {", src, dst),
+				fmt.Sprintf("return %s, err", zero),
+				"}",
+				"}",
+			}
+		},
+	}
+}
+
+// scalar returns the non-null scalar of the Go type, registering the custom
+// scalars and the enums used by the schema.
+func (s *graphqlSchema) scalar(goType string) (graphqlScalar, bool) {
+	if e, null, ok := s.enums.lookup(goType); ok && !null && !strings.HasPrefix(goType, "[]") {
+		if !containsEnum(s.usedEnums, e) {
+			s.usedEnums = append(s.usedEnums, e)
+		}
+		return graphqlScalar{
+			name:     e.Name,
+			resolver: "string",
+			out:      func(v string) string { return fmt.Sprintf("toGraphql%s(%s)", e.Name, v) },
+			in:       func(v string) string { return fmt.Sprintf("fromGraphql%s(%s)", e.Name, v) },
+		}, true
+	}
+	scalar, ok := graphqlScalars[goType]
+	if !ok {
+		return scalar, false
+	}
+	switch scalar.name {
+	case "Int64", "Time", "UUID":
+		s.scalars[scalar.name] = struct{}{}
+	}
+	return scalar, true
+}
+
+func containsEnum(enums []*serverEnum, e *serverEnum) bool {
+	for _, other := range enums {
+		if other == e {
+			return true
+		}
+	}
+	return false
+}
+
+// object returns the object of the struct, registering it with the objects of
+// its fields.
+func (s *graphqlSchema) object(msg *metadata.Message) *graphqlObject {
+	for _, obj := range s.objects {
+		if obj.Name == msg.Name {
+			return obj
+		}
+	}
+	obj := graphqlObject{Name: msg.Name}
+	s.objects = append(s.objects, &obj)
+	for _, f := range msg.Fields {
+		obj.fields = append(obj.fields, &graphqlValue{
+			Name:   graphqlName(f.Name),
+			GoName: f.Name,
+			Type:   s.typeOf(f.Type),
+		})
+	}
+	return &obj
+}
+
+// graphqlEnumValue returns the name of the value in the GraphQL enum: the
+// name of the proto value, without the prefix of the enum.
+func graphqlEnumValue(e *serverEnum, v serverEnumValue) string {
+	name := strings.TrimPrefix(v.Proto, protoEnumPrefix(e.Name)+"_")
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		// the names can't start with a digit
+		return v.Proto
+	}
+	return name
+}
+
+// sdl returns the schema of the package in the GraphQL Schema Definition
+// Language. The blocks are separated by a blank line, so the schemas of the
+// packages can be merged by the server.
+func (s *graphqlSchema) sdl() []string {
+	res := make([]string, 0)
+	block := func(lines ...string) {
+		if len(res) > 0 {
+			res = append(res, "")
+		}
+		res = append(res, lines...)
+	}
+	if len(s.scalars) > 0 {
+		scalars := make([]string, 0, len(s.scalars))
+		for name := range s.scalars {
+			scalars = append(scalars, "scalar "+name)
+		}
+		sort.Strings(scalars)
+		block(scalars...)
+	}
+	for _, e := range s.usedEnums {
+		lines := []string{fmt.Sprintf("enum %s {", e.Name)}
+		for _, v := range e.Values {
+			lines = append(lines, "  "+graphqlEnumValue(e, v))
+		}
+		block(append(lines, "}")...)
+	}
+	for _, obj := range s.objects {
+		lines := []string{fmt.Sprintf("type %s {", obj.Name)}
+		for _, f := range obj.fields {
+			lines = append(lines, fmt.Sprintf("  %s: %s", f.Name, f.Type.Name))
+		}
+		block(append(lines, "}")...)
+	}
+	for _, root := range []struct {
+		name     string
+		mutation bool
+	}{{"Query", false}, {"Mutation", true}} {
+		lines := []string{fmt.Sprintf("type %s {", root.name)}
+		for _, f := range s.fields {
+			if f.mutation == root.mutation {
+				lines = append(lines, "  "+f.sdl())
+			}
+		}
+		if len(lines) > 1 {
+			block(append(lines, "}")...)
+		}
+	}
+	return res
+}
+
+func (f *graphqlField) sdl() string {
+	args := make([]string, 0, len(f.args)+2)
+	for _, arg := range f.args {
+		args = append(args, fmt.Sprintf("%s: %s", arg.Name, arg.Type.Name))
+	}
+	if f.page != nil {
+		args = append(args, "pageSize: Int", "pageToken: String")
+	}
+	var b strings.Builder
+	b.WriteString(converter.LowerFirstCharacter(f.svc.Name))
+	if len(args) > 0 {
+		b.WriteString("(" + strings.Join(args, ", ") + ")")
+	}
+	b.WriteString(": " + f.result.Name)
+	return b.String()
+}
+
+// declare returns the lines declaring the variable assigned by the lines.
+func declare(name, typ string, lines []string) []string {
+	if len(lines) == 1 {
+		if value, ok := strings.CutPrefix(lines[0], name+" = "); ok {
+			return []string{fmt.Sprintf("%s := %s", name, value)}
+		}
+	}
+	return append([]string{fmt.Sprintf("var %s %s", name, typ)}, lines...)
+}
+
+// zeroValue returns the zero value of the resolver type, returned with the
+// errors.
+func zeroValue(resolver string) string {
+	switch {
+	case strings.HasPrefix(resolver, "*"), strings.HasPrefix(resolver, "[]"):
+		return "nil"
+	case resolver == "bool":
+		return "false"
+	case resolver == "string":
+		return `""`
+	case resolver == "graphql.Time", resolver == "server.UUID":
+		return resolver + "{}"
+	}
+	return "0"
+}
+
+// resolvers returns the methods of the Service resolving the fields of the
+// regular or of the page queries.
+func (s *graphqlSchema) resolvers(page bool) []string {
+	res := make([]string, 0)
+	for _, f := range s.fields {
+		if (f.page != nil) != page {
+			continue
+		}
+		res = append(res, "")
+		res = append(res, f.resolver(s)...)
+	}
+	return res
+}
+
+func (f *graphqlField) resolver(s *graphqlSchema) []string {
+	svc := f.svc
+	name := converter.UpperFirstCharacter(svc.Name)
+	zero := zeroValue(f.result.Resolver)
+	kind := "query"
+	if f.mutation {
+		kind = "mutation"
+	}
+	res := []string{fmt.Sprintf("// %s resolves the %s %s.", name, converter.LowerFirstCharacter(svc.Name), kind)}
+	args := make([]string, 0, len(f.args)+2)
+	for _, arg := range f.args {
+		args = append(args, fmt.Sprintf("%s %s", arg.GoName, arg.Type.Resolver))
+	}
+	if f.page != nil {
+		args = append(args, "PageSize *int32", "PageToken *string")
+	}
+	if len(args) > 0 {
+		res = append(res, fmt.Sprintf("func (s *Service) %s(ctx context.Context, args struct {", name))
+		res = append(res, args...)
+		res = append(res, fmt.Sprintf("}) (%s, error) {", f.result.Resolver))
+	} else {
+		res = append(res, fmt.Sprintf("func (s *Service) %s(ctx context.Context) (%s, error) {", name, f.result.Resolver))
+	}
+	if !svc.EmptyInput() {
+		param := svc.InputNames[0]
+		typ := svc.InputTypes[0]
+		if len(f.args) == 1 && f.args[0].GoName == converter.UpperFirstCharacter(param) && !svc.HasCustomParams() {
+			res = append(res, declare(param, typ, f.args[0].Type.in(param, "args."+f.args[0].GoName, zero))...)
+		} else {
+			if ptr, ok := strings.CutPrefix(typ, "*"); ok {
+				res = append(res, fmt.Sprintf("%s := new(%s)", param, ptr))
+			} else {
+				res = append(res, fmt.Sprintf("var %s %s", param, typ))
+			}
+			for _, arg := range f.args {
+				res = append(res, arg.Type.in(param+"."+arg.GoName, "args."+arg.GoName, zero)...)
+			}
+		}
+	}
+	res = append(res, s.validators.input(svc, fmt.Sprintf("return %s, err", zero))...)
+	var db string
+	if s.pkg.EmitDbArgument {
+		db = ", s.db"
+	}
+	callErr := []string{
+		"if err != nil {",
+		fmt.Sprintf("slog.Error(\"sql call failed\", \"error\", err, \"method\", %q)", svc.Name),
+		fmt.Sprintf("return %s, dberrors.Error(err)", zero),
+		"}",
+	}
+	if f.page != nil {
+		res = append(res, "var pageToken string")
+		res = append(res, "if args.PageToken != nil {")
+		res = append(res, "pageToken = *args.PageToken")
+		res = append(res, "}")
+		res = append(res, fmt.Sprintf("after, err := decodePageToken[%s](pageToken)", f.page.CursorType))
+		res = append(res, "if err != nil {")
+		res = append(res, "return nil, err")
+		res = append(res, "}")
+		res = append(res, "pageSize := pageLimit(args.PageSize)")
+		res = append(res, fmt.Sprintf("result, err := s.querier.%sPage(ctx%s%s, after, pageSize+1)", svc.Name, db, svc.ParamsCallDatabase()))
+		res = append(res, callErr...)
+		res = append(res, fmt.Sprintf("var res %s", strings.TrimPrefix(f.result.Resolver, "*")))
+		res = append(res, "if len(result) > int(pageSize) {")
+		res = append(res, "result = result[:pageSize]")
+		res = append(res, "last := result[len(result)-1]")
+		res = append(res, fmt.Sprintf("if res.NextPageToken, err = encodePageToken(%s); err != nil {", f.page.NextCursor))
+		res = append(res, "return nil, err")
+		res = append(res, "}")
+		res = append(res, "}")
+		list := s.resultType(svc)
+		res = append(res, list.out("res.List", "result")...)
+		res = append(res, "return &res, nil")
+		return append(res, "}")
+	}
+	if svc.EmptyOutput() {
+		res = append(res, fmt.Sprintf("err := s.querier.%s(ctx%s%s)", svc.Name, db, svc.ParamsCallDatabase()))
+	} else {
+		res = append(res, fmt.Sprintf("result, err := s.querier.%s(ctx%s%s)", svc.Name, db, svc.ParamsCallDatabase()))
+	}
+	res = append(res, callErr...)
+	switch svc.Output {
+	case "":
+		res = append(res, "return true, nil")
+	case "sql.Result":
+		res = append(res, "rowsAffected, err := result.RowsAffected()")
+		res = append(res, "if err != nil {")
+		res = append(res, "return 0, err")
+		res = append(res, "}")
+		res = append(res, "return server.Int64(rowsAffected), nil")
+	case "pgconn.CommandTag":
+		res = append(res, "return server.Int64(result.RowsAffected()), nil")
+	default:
+		lines := f.result.out("res", "result")
+		if value, ok := strings.CutPrefix(lines[0], "res = "); ok && len(lines) == 1 {
+			res = append(res, fmt.Sprintf("return %s, nil", value))
+			break
+		}
+		res = append(res, fmt.Sprintf("var res %s", f.result.Resolver))
+		res = append(res, lines...)
+		res = append(res, "return res, nil")
+	}
+	return append(res, "}")
+}
+
+// adapters returns the types resolving the objects, the functions converting
+// the structs generated by sqlc to them, and the conversions of the enums.
+func (s *graphqlSchema) adapters() []string {
+	res := make([]string, 0)
+	for _, obj := range s.objects {
+		res = append(res, "")
+		res = append(res, fmt.Sprintf("type %s struct {", obj.goType()))
+		for _, f := range obj.fields {
+			res = append(res, fmt.Sprintf("%s %s", f.GoName, f.Type.Resolver))
+		}
+		res = append(res, "}")
+		if obj.page {
+			continue
+		}
+		res = append(res, "")
+		res = append(res, fmt.Sprintf("func to%sObject(r %s) *%s {", obj.Name, obj.Name, obj.goType()))
+		res = append(res, fmt.Sprintf("var res %s", obj.goType()))
+		for _, f := range obj.fields {
+			res = append(res, f.Type.out("res."+f.GoName, "r."+f.GoName)...)
+		}
+		res = append(res, "return &res")
+		res = append(res, "}")
+	}
+	for _, e := range s.usedEnums {
+		res = append(res, "")
+		res = append(res, fmt.Sprintf("func toGraphql%s(v %s) string {", e.Name, e.Name))
+		res = append(res, "switch v {")
+		for _, v := range e.Values {
+			res = append(res, fmt.Sprintf("case %s:", v.Constant))
+			res = append(res, fmt.Sprintf("return %q", graphqlEnumValue(e, v)))
+		}
+		res = append(res, "}")
+		res = append(res, "return string(v)")
+		res = append(res, "}")
+		res = append(res, "")
+		res = append(res, fmt.Sprintf("func fromGraphql%s(v string) %s {", e.Name, e.Name))
+		res = append(res, "switch v {")
+		for _, v := range e.Values {
+			res = append(res, fmt.Sprintf("case %q:", graphqlEnumValue(e, v)))
+			res = append(res, fmt.Sprintf("return %s", v.Constant))
+		}
+		res = append(res, "}")
+		res = append(res, fmt.Sprintf("return %s(v)", e.Name))
+		res = append(res, "}")
+	}
+	return res
+}
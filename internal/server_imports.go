@@ -0,0 +1,267 @@
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+)
+
+// organizeImports removes the imports not used by a generated server source
+// and groups the remaining ones in standard library, dependencies and
+// packages of the generated module. The package names referenced without an
+// import are resolved by the index.
+func organizeImports(src []byte, goModule string, index importIndex) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// the identifiers resolved to an import are package names, the undefined
+	// ones are package names or declarations from other files of the same
+	// package. The packages are empty, so the type errors are ignored.
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	conf := types.Config{
+		Importer: emptyImporter{},
+		Error:    func(error) {},
+	}
+	conf.Check("", fset, []*ast.File{f}, info)
+	used := make(map[string]struct{})
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				switch info.Uses[id].(type) {
+				case *types.PkgName, nil:
+					used[id.Name] = struct{}{}
+				}
+			}
+		}
+		return true
+	})
+
+	var std, dep, local []ImportSpec
+	seen := make(map[string]struct{})
+	imported := make(map[string]struct{})
+	blank := make(map[string]string)
+	for _, imp := range f.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		spec := ImportSpec{Path: importPath}
+		if imp.Name != nil {
+			spec.ID = imp.Name.Name
+		}
+		if _, ok := seen[spec.String()]; ok {
+			continue
+		}
+		seen[spec.String()] = struct{}{}
+		switch spec.ID {
+		case "_":
+			// the packages imported for side effects may be referenced too
+			if _, ok := blank[importPathToAssumedName(importPath)]; !ok {
+				blank[importPathToAssumedName(importPath)] = importPath
+			}
+		case ".":
+		default:
+			name := spec.ID
+			if name == "" {
+				name = importPathToAssumedName(importPath)
+			}
+			if _, ok := used[name]; !ok {
+				continue
+			}
+			imported[name] = struct{}{}
+		}
+		switch {
+		case importPath == goModule || strings.HasPrefix(importPath, goModule+"/"):
+			local = append(local, spec)
+		case isStdImport(importPath):
+			std = append(std, spec)
+		default:
+			dep = append(dep, spec)
+		}
+	}
+
+	for name := range used {
+		if _, ok := imported[name]; ok {
+			continue
+		}
+		importPath, ok := blank[name]
+		if !ok {
+			if importPath, ok = index[name]; !ok {
+				continue
+			}
+		}
+		spec := ImportSpec{Path: importPath}
+		if importPathToAssumedName(importPath) != name {
+			spec.ID = name
+		}
+		switch {
+		case strings.HasPrefix(importPath, goModule+"/"):
+			local = append(local, spec)
+		case isStdImport(importPath):
+			std = append(std, spec)
+		default:
+			dep = append(dep, spec)
+		}
+	}
+
+	// the database driver is imported for side effects by the templates even
+	// when its package is referenced
+	for _, specs := range []*[]ImportSpec{&std, &dep, &local} {
+		*specs = slices.DeleteFunc(*specs, func(spec ImportSpec) bool {
+			return spec.ID == "_" && slices.ContainsFunc(*specs, func(other ImportSpec) bool {
+				return other.Path == spec.Path && other.ID != "_"
+			})
+		})
+	}
+
+	var decl bytes.Buffer
+	var groups int
+	for _, group := range [][]ImportSpec{std, dep, local} {
+		if len(group) == 0 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].Path < group[j].Path })
+		if groups > 0 {
+			decl.WriteString("\n")
+		}
+		for _, spec := range group {
+			fmt.Fprintf(&decl, "\t%s\n", spec)
+		}
+		groups++
+	}
+
+	var start, end token.Pos
+	for _, d := range f.Decls {
+		gen, ok := d.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		if start == token.NoPos {
+			start = gen.Pos()
+		}
+		end = gen.End()
+	}
+
+	var b bytes.Buffer
+	if start == token.NoPos {
+		// no import declaration, add one right after the package clause
+		start, end = f.Name.End(), f.Name.End()
+		b.Write(src[:fset.Position(start).Offset])
+		b.WriteString("\n\n")
+	} else {
+		b.Write(src[:fset.Position(start).Offset])
+	}
+	if groups > 0 {
+		b.WriteString("import (\n")
+		b.Write(decl.Bytes())
+		b.WriteString(")")
+	}
+	b.Write(src[fset.Position(end).Offset:])
+
+	return format.Source(b.Bytes())
+}
+
+func isStdImport(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// importPathToAssumedName returns the package name usually declared by the
+// package of the import path, using the same heuristic as goimports.
+func importPathToAssumedName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			if dir := path.Dir(importPath); dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '_' && !unicode.IsDigit(r)
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// emptyImporter imports the packages without declarations, named like
+// importPathToAssumedName, to resolve the package names of a source without
+// its dependencies.
+type emptyImporter struct{}
+
+func (emptyImporter) Import(importPath string) (*types.Package, error) {
+	pkg := types.NewPackage(importPath, importPathToAssumedName(importPath))
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+// importIndex resolves the package names referenced by the generated sources
+// without a matching import, like in the code added by the template
+// functions, to the packages of the generated module and to the packages
+// imported by the generated sources.
+type importIndex map[string]string
+
+// newImportIndex indexes the generated files, named relative to the out
+// directory of the queries.
+func newImportIndex(goModule, out, toRootPath string, files []*plugin.File) (importIndex, error) {
+	index := make(importIndex)
+	fset := token.NewFileSet()
+	parsed := make([]*ast.File, 0, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file.Name, file.Contents, parser.ImportsOnly)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		parsed = append(parsed, f)
+		if f.Name.Name == "main" {
+			continue
+		}
+		dir := filepath.Dir(file.Name)
+		if rel, ok := strings.CutPrefix(dir, toRootPath); ok && (rel == "" || strings.HasPrefix(rel, string(filepath.Separator))) {
+			dir = strings.TrimPrefix(rel, string(filepath.Separator))
+		} else {
+			dir = filepath.Join(out, dir)
+		}
+		if _, ok := index[f.Name.Name]; !ok && dir != "" && dir != "." {
+			index[f.Name.Name] = goModule + "/" + filepath.ToSlash(dir)
+		}
+	}
+	// the packages of the module take precedence over the imports
+	for _, f := range parsed {
+		for _, imp := range f.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			name := importPathToAssumedName(importPath)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			if _, ok := index[name]; !ok && name != "_" && name != "." {
+				index[name] = importPath
+			}
+		}
+	}
+	return index, nil
+}
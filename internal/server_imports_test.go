@@ -0,0 +1,142 @@
+package golang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+)
+
+func TestOrganizeImports(t *testing.T) {
+	src := `package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mattn/go-sqlite3"
+
+	"example.com/app/internal/server"
+)
+
+func run(db *pgxpool.Pool) error {
+	server := &http.Server{}
+	fmt.Println(filepath.Join("a", "b"))
+	return server.ListenAndServe()
+}
+`
+	want := `package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func run(db *pgxpool.Pool) error {
+	server := &http.Server{}
+	fmt.Println(filepath.Join("a", "b"))
+	return server.ListenAndServe()
+}
+`
+	got, err := organizeImports([]byte(src), "example.com/app", importIndex{"filepath": "path/filepath"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("organizeImports mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImportIndex(t *testing.T) {
+	files := []*plugin.File{
+		{Name: "service.go", Contents: []byte("package authors\n\nimport (\n\t\"net/http\"\n\n\tsrv \"example.com/app/internal/server\"\n)\n")},
+		{Name: "../../internal/auth/auth.go", Contents: []byte("package auth\n\nimport \"github.com/jackc/pgx/v5/pgxpool\"\n")},
+		{Name: "../../registry.go", Contents: []byte("package main\n\nimport \"path/filepath\"\n")},
+		{Name: "../../openapi.yml", Contents: []byte("openapi: 3.0.1\n")},
+	}
+	index, err := newImportIndex("example.com/app", "internal/authors", "../..", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := importIndex{
+		"auth":     "example.com/app/internal/auth",
+		"authors":  "example.com/app/internal/authors",
+		"filepath": "path/filepath",
+		"http":     "net/http",
+		"pgxpool":  "github.com/jackc/pgx/v5/pgxpool",
+		"srv":      "example.com/app/internal/server",
+	}
+	if diff := cmp.Diff(want, index); diff != "" {
+		t.Errorf("newImportIndex mismatch (-want +got):\n%s", diff)
+	}
+
+	// a local variable shadowing a package name isn't a package reference
+	src := `package authors
+
+func handle(tenant string) string {
+	return tenant.String() + auth.User()
+}
+`
+	got, err := organizeImports([]byte(src), "example.com/app", importIndex{"tenant": "example.com/app/internal/tenant", "auth": "example.com/app/internal/auth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "\"example.com/app/internal/auth\"") || strings.Contains(string(got), "internal/tenant") {
+		t.Errorf("organizeImports resolved the wrong packages:\n%s", got)
+	}
+}
+
+func TestImportPathToAssumedName(t *testing.T) {
+	for path, want := range map[string]string{
+		"net/http":                         "http",
+		"github.com/jackc/pgx/v5":          "pgx",
+		"github.com/mattn/go-sqlite3":      "sqlite3",
+		"gopkg.in/yaml.v3":                 "yaml",
+		"github.com/superfly/litefs-go":    "litefs",
+		"go.opentelemetry.io/otel/semconv": "semconv",
+	} {
+		if got := importPathToAssumedName(path); got != want {
+			t.Errorf("importPathToAssumedName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestGoModFile(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+
+	"example.com/app/internal/server"
+)
+`
+	want := `module example.com/app
+
+go 1.22
+
+require (
+	github.com/jackc/pgx/v5 v5.5.5
+)
+
+// The modules of these imports aren't pinned by the plugin, run go mod tidy
+// to require them:
+//	github.com/shopspring/decimal
+`
+	got, err := goModFile("example.com/app", "http", []*plugin.File{{Name: "main.go", Contents: []byte(src)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("goModFile mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,308 @@
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonrpcBaseTemplates returns the templates of the http server shared by the
+// jsonrpc server: the main and database files, the instrumentation, LiteFS,
+// Litestream, migrations and the validation of the requests. The REST routes,
+// the OpenAPI, the client and the endpoints of the batch, copyfrom and stream
+// queries aren't generated.
+func jsonrpcBaseTemplates(httpFS fs.FS) fs.FS {
+	return excludeFS{
+		base: httpFS,
+		names: []string{
+			"routes.go.tmpl", "openapi.yml.tmpl", "client/client.go.tmpl", "client/client.ts.tmpl",
+			"service.batch.go.tmpl", "service.copyfrom.go.tmpl", "service.stream.go.tmpl",
+			"internal/server/encoding.go.tmpl", "internal/server/records.go.tmpl", "internal/server/stream.go.tmpl",
+		},
+	}
+}
+
+func jsonrpcFuncs(funcs template.FuncMap, enums serverEnums, validators serverValidators) template.FuncMap {
+	res := httpFuncs(funcs, enums, validators)
+	input := funcs["Input"].(func(*metadata.Service) []string)
+	res["Input"] = func(s *metadata.Service) []string {
+		return append(jsonrpcInput(input(s)), validators.input(s, "return nil, jsonrpc.InvalidParams(err)")...)
+	}
+	output := res["Output"].(func(*metadata.Service) []string)
+	res["Output"] = func(s *metadata.Service) []string {
+		if s.EmptyOutput() {
+			return []string{"return nil, nil"}
+		}
+		return jsonrpcOutput(output(s))
+	}
+	res["JsonrpcServices"] = jsonrpcServices
+	res["PageInput"] = func(s *pageService) []string {
+		page := *s
+		page.Service = jsonrpcService(s.Service)
+		return append(jsonrpcInput(pageInputHttp(&page)), validators.input(s.Service, "return nil, jsonrpc.InvalidParams(err)")...)
+	}
+	apiParameters := res["ApiParameters"].(func(*metadata.Service) []string)
+	apiResponse := res["ApiResponse"].(func(*metadata.Service) []string)
+	apiComponentSchemas := res["ApiComponentSchemas"].(func(*metadata.Package) []string)
+	res["OpenRPC"] = func(pkg *serverPackage) (string, error) {
+		return openRPC(pkg, apiParameters, apiResponse, apiComponentSchemas)
+	}
+	return res
+}
+
+// jsonrpcService returns the service called by a JSON-RPC method. The params
+// of the methods are decoded like the body of a POST request.
+func jsonrpcService(s *metadata.Service) *metadata.Service {
+	svc := *s
+	svc.HttpSpecs = []metadata.HttpSpec{{Method: "POST", Path: "/" + s.Name}}
+	return &svc
+}
+
+// jsonrpcServices returns the services exposed as JSON-RPC methods, except the
+// page services. The rows of the stream queries are returned as a list and
+// the batch and copyfrom queries aren't exposed: the clients group the calls
+// in a JSON-RPC batch instead.
+func jsonrpcServices(pkg *serverPackage) []*metadata.Service {
+	res := make([]*metadata.Service, 0, len(pkg.Services)+len(pkg.StreamServices))
+	for _, s := range pkg.Services {
+		res = append(res, jsonrpcService(s))
+	}
+	for _, s := range pkg.StreamServices {
+		svc := jsonrpcService(s.Service)
+		svc.Output = "[]" + s.Output
+		svc.CustomSpecs = maps.Clone(s.CustomSpecs)
+		delete(svc.CustomSpecs, "stream")
+		res = append(res, svc)
+	}
+	return res
+}
+
+// jsonrpcInput converts the lines decoding the body of a POST request to
+// decode the params of the call, returning the invalid params errors.
+func jsonrpcInput(lines []string) []string {
+	res := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case line == "req, err := server.Decode[request](r)":
+			res = append(res, "var req request")
+			res = append(res, "if err := jsonrpc.DecodeParams(params, &req); err != nil {")
+			res = append(res, "return nil, err")
+			res = append(res, "}")
+			// skip the http error
+			i += 2
+		case strings.HasPrefix(line, "http.Error(w, err.Error(), "):
+			res = append(res, "return nil, jsonrpc.InvalidParams(err)")
+			if i+1 < len(lines) {
+				// the next line returns from the http handler
+				next := strings.TrimPrefix(lines[i+1], "return nil, err")
+				if next == lines[i+1] {
+					next = strings.TrimPrefix(next, "return")
+				}
+				res = append(res, strings.TrimSpace(next))
+				i++
+			}
+		default:
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// jsonrpcOutput converts the lines encoding the response of the http handler
+// to return the result of the call.
+func jsonrpcOutput(lines []string) []string {
+	const encode = "server.Encode(w, r, http.StatusOK, "
+	res := make([]string, 0, len(lines))
+	var multiline bool
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, encode) && strings.HasSuffix(line, "{"):
+			res = append(res, "return "+strings.TrimPrefix(line, encode))
+			multiline = true
+		case strings.HasPrefix(line, encode):
+			res = append(res, fmt.Sprintf("return %s, nil", strings.TrimSuffix(strings.TrimPrefix(line, encode), ")")))
+		case multiline && line == "})":
+			res = append(res, "}, nil")
+			multiline = false
+		default:
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// openRPCDocument is the OpenRPC document describing the methods of the
+// jsonrpc server. The schemas are converted from the OpenAPI schemas of the
+// same services, described by the sqlc-http funcs.
+type openRPCDocument struct {
+	OpenRPC    string             `json:"openrpc"`
+	Info       openRPCInfo        `json:"info"`
+	Methods    []*openRPCMethod   `json:"methods"`
+	Components *openRPCComponents `json:"components,omitempty"`
+}
+
+type openRPCInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openRPCMethod struct {
+	Name           string               `json:"name"`
+	Tags           []openRPCTag         `json:"tags"`
+	ParamStructure string               `json:"paramStructure"`
+	Params         []*openRPCDescriptor `json:"params"`
+	Result         *openRPCDescriptor   `json:"result"`
+}
+
+type openRPCTag struct {
+	Name string `json:"name"`
+}
+
+// openRPCDescriptor is a content descriptor: a param or the result of a
+// method.
+type openRPCDescriptor struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required,omitempty"`
+	Schema   any    `json:"schema"`
+}
+
+type openRPCComponents struct {
+	Schemas map[string]any `json:"schemas"`
+}
+
+func openRPC(pkg *serverPackage, apiParameters, apiResponse func(*metadata.Service) []string, apiComponentSchemas func(*metadata.Package) []string) (string, error) {
+	doc := openRPCDocument{
+		OpenRPC: "1.2.6",
+		Info: openRPCInfo{
+			Title:       pkg.GoModule,
+			Description: pkg.GoModule + " Services",
+			Version:     "0.0.1",
+		},
+		Methods: make([]*openRPCMethod, 0),
+	}
+	services := jsonrpcServices(pkg)
+	for _, s := range pkg.PageServices {
+		services = append(services, jsonrpcService(pageApiService(s)))
+	}
+	slices.SortStableFunc(services, func(a, b *metadata.Service) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, s := range services {
+		method := openRPCMethod{
+			Name:           s.Name,
+			Tags:           []openRPCTag{{Name: pkg.Package}},
+			ParamStructure: "by-name",
+			Params:         make([]*openRPCDescriptor, 0),
+			Result:         &openRPCDescriptor{Name: "result", Schema: map[string]any{"type": "null"}},
+		}
+		params, err := openRPCSchema(apiParameters(s), "requestBody", "content", "application/json", "schema")
+		if err != nil {
+			return "", fmt.Errorf("openrpc params of %s: %w", s.Name, err)
+		}
+		if params["type"] == "array" {
+			method.Params = append(method.Params, &openRPCDescriptor{Name: s.InputNames[0], Required: true, Schema: params})
+		} else if properties, ok := params["properties"].(map[string]any); ok {
+			required, _ := params["required"].([]any)
+			for _, name := range jsonrpcParamNames(s) {
+				schema, ok := properties[name]
+				if !ok {
+					continue
+				}
+				method.Params = append(method.Params, &openRPCDescriptor{
+					Name:     name,
+					Required: slices.Contains(required, any(name)),
+					Schema:   schema,
+				})
+			}
+		}
+		result, err := openRPCSchema(apiResponse(s), "content", "application/json", "schema")
+		if err != nil {
+			return "", fmt.Errorf("openrpc result of %s: %w", s.Name, err)
+		}
+		if result != nil {
+			method.Result.Schema = result
+		}
+		doc.Methods = append(doc.Methods, &method)
+	}
+	schemas, err := openRPCSchema(apiComponentSchemas(pkg.apiPackage()))
+	if err != nil {
+		return "", fmt.Errorf("openrpc components: %w", err)
+	}
+	if len(schemas) > 0 {
+		doc.Components = &openRPCComponents{Schemas: schemas}
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// jsonrpcParamNames returns the names of the params of the service, in the
+// order of the fields of the request.
+func jsonrpcParamNames(s *metadata.Service) []string {
+	res := make([]string, 0)
+	for i, typ := range s.InputTypes {
+		if m, ok := s.Messages[converter.CanonicalName(typ)]; ok {
+			for _, f := range m.Fields {
+				res = append(res, converter.ToSnakeCase(converter.CanonicalName(f.Name)))
+			}
+			continue
+		}
+		res = append(res, converter.ToSnakeCase(converter.CanonicalName(s.InputNames[i])))
+	}
+	return res
+}
+
+// openRPCSchema parses the OpenAPI described by the lines and returns the
+// value at the path, or nil if it's absent. The null values, like the empty
+// formats, are removed.
+func openRPCSchema(lines []string, path ...string) (map[string]any, error) {
+	src := make([]string, 0, len(lines))
+	for _, line := range lines {
+		// sqlc-http marks some nullable types, like *boolean, which would be
+		// read as a YAML alias
+		src = append(src, strings.Replace(line, "type: *", "type: ", 1))
+	}
+	var v any
+	if err := yaml.Unmarshal([]byte(strings.Join(src, "\n")), &v); err != nil {
+		return nil, err
+	}
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		v = m[key]
+	}
+	m, _ := dropNulls(v).(map[string]any)
+	return m, nil
+}
+
+func dropNulls(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if value == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = dropNulls(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,75 @@
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// mcpBaseTemplates returns the templates of the jsonrpc server shared by the
+// mcp server, whose tools are called by JSON-RPC. The OpenRPC document isn't
+// generated, the tools are described by the tools.json of the packages.
+func mcpBaseTemplates(httpFS fs.FS) (fs.FS, error) {
+	jsonrpcFS, err := serverTemplatesFS("jsonrpc", jsonrpcBaseTemplates(httpFS))
+	if err != nil {
+		return nil, err
+	}
+	return excludeFS{base: jsonrpcFS, names: []string{"openrpc.json.tmpl"}}, nil
+}
+
+func mcpFuncs(funcs template.FuncMap, enums serverEnums, validators serverValidators) template.FuncMap {
+	res := jsonrpcFuncs(funcs, enums, validators)
+	apiParameters := res["ApiParameters"].(func(*metadata.Service) []string)
+	res["McpTools"] = func(pkg *serverPackage) (string, error) {
+		return mcpTools(pkg, apiParameters)
+	}
+	return res
+}
+
+// mcpTool is the definition of a tool listed by the MCP server.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// mcpTools returns the tools.json of the package: the tools calling the
+// queries, described by the comments of the queries. The input schemas are
+// the OpenAPI schemas of the params of the JSON-RPC methods.
+func mcpTools(pkg *serverPackage, apiParameters func(*metadata.Service) []string) (string, error) {
+	services := jsonrpcServices(pkg)
+	for _, s := range pkg.PageServices {
+		services = append(services, jsonrpcService(pageApiService(s)))
+	}
+	slices.SortStableFunc(services, func(a, b *metadata.Service) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	tools := make([]*mcpTool, 0, len(services))
+	for _, s := range services {
+		schema, err := openRPCSchema(apiParameters(s), "requestBody", "content", "application/json", "schema")
+		if err != nil {
+			return "", fmt.Errorf("input schema of %s: %w", s.Name, err)
+		}
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		if _, ok := schema["properties"]; !ok {
+			schema["properties"] = map[string]any{}
+		}
+		tools = append(tools, &mcpTool{
+			Name:        s.Name,
+			Description: strings.Join(pkg.Docs[s.Name], " "),
+			InputSchema: schema,
+		})
+	}
+	b, err := json.MarshalIndent(tools, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
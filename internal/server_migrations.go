@@ -0,0 +1,42 @@
+package golang
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+var migrationVersionPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// migrationVersion checks the version of a migration emitted in the
+// migration_path, given by the option with the name. The version orders the
+// migration among the migrations of the project, so it's required.
+func migrationVersion(option, version string) (string, error) {
+	switch {
+	case version == "":
+		return "", fmt.Errorf("the %s option is required with the migration_path option, use the number ordering the migration among yours like 20240101000000", option)
+	case !migrationVersionPattern.MatchString(version):
+		return "", fmt.Errorf("invalid %s %q, use the number of the migration like 20240101000000", option, version)
+	}
+	return version, nil
+}
+
+// migrationFiles returns a migration in the migration_path, in the format of
+// the migration library: one goose file with the up and down sections, or
+// the up and down files of migrate.
+func migrationFiles(def *metadata.Definition, toRootPath, version, name, up, down string) []*plugin.File {
+	const header = "-- Code generated by sqlc-gen-go-server (https://github.com/walterwanderley/sqlc-gen-go-server). DO NOT EDIT.\n\n"
+	file := filepath.Join(toRootPath, def.MigrationPath, version+"_"+name)
+	if def.MigrationLib == "migrate" {
+		return []*plugin.File{
+			{Name: file + ".up.sql", Contents: []byte(header + up)},
+			{Name: file + ".down.sql", Contents: []byte(header + down)},
+		}
+	}
+	return []*plugin.File{
+		{Name: file + ".sql", Contents: []byte(header + "-- +goose Up\n" + up + "\n-- +goose Down\n" + down)},
+	}
+}
@@ -0,0 +1,71 @@
+package golang
+
+import (
+	"io/fs"
+	"maps"
+	"text/template"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// outboxTemplatesFS adds the outbox package, with the relay of the events, to
+// the templates.
+func outboxTemplatesFS(base fs.FS) (fs.FS, error) {
+	top, err := fs.Sub(serverTemplates, "server_templates/outbox")
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: top, base: base}, nil
+}
+
+// outboxTable returns the DDL of the outbox table. The events claimed by a
+// relay have the claimed_until lease, and the published events the
+// published_at time.
+func outboxTable(engine string) string {
+	switch engine {
+	case "postgresql":
+		return `CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	topic TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	claimed_until TIMESTAMPTZ,
+	published_at TIMESTAMPTZ
+)`
+	case "mysql":
+		return `CREATE TABLE IF NOT EXISTS outbox (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	payload JSON NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	claimed_until TIMESTAMP NULL,
+	published_at TIMESTAMP NULL
+)`
+	}
+	return `CREATE TABLE IF NOT EXISTS outbox (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	claimed_until TIMESTAMP,
+	published_at TIMESTAMP
+)`
+}
+
+// outboxMigrations returns the migration of the outbox table in the
+// migration_path.
+func outboxMigrations(def *metadata.Definition, toRootPath, version string) []*plugin.File {
+	up := outboxTable(def.Database()) + ";\n"
+	down := "DROP TABLE IF EXISTS outbox;\n"
+	return migrationFiles(def, toRootPath, version, "outbox", up, down)
+}
+
+// outboxFuncs adds the OutboxEnabled function, reporting to the registries if
+// the relay is started, and the OutboxTable function.
+func outboxFuncs(funcs template.FuncMap, engine string, enabled bool) template.FuncMap {
+	res := maps.Clone(funcs)
+	res["OutboxEnabled"] = func() bool { return enabled }
+	res["OutboxTable"] = func() string { return outboxTable(engine) }
+	return res
+}
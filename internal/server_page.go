@@ -0,0 +1,196 @@
+package golang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// pageService exposes a :many query annotated with "-- paginate:". The
+// request has the page_size and page_token fields and the response has the
+// next_page_token requesting the rows after the last row of the page.
+type pageService struct {
+	*metadata.Service
+	// CursorType is the cursor type generated by sqlc for the <Name>Page method.
+	CursorType string
+	// NextCursor builds the cursor from the last row of the page.
+	NextCursor string
+}
+
+// isPage reports if the service was created from a page service, the funcs
+// shared with the regular services use it to describe the page.
+func isPage(s *metadata.Service) bool {
+	_, ok := s.CustomSpecs["paginate"]
+	return ok
+}
+
+// nextCursor returns the expression building the cursor of the query from
+// the last row of a page.
+func nextCursor(query Query) string {
+	fields := make([]string, 0, len(query.Page.Cursor.Fields))
+	for _, f := range query.Page.Cursor.Fields {
+		src := "last"
+		if query.Ret.Struct != nil {
+			for _, rf := range query.Ret.Struct.Fields {
+				if rf.DBName == f.DBName || (rf.DBName == "" && rf.Name == f.Name) {
+					src = "last." + rf.Name
+					break
+				}
+			}
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s", f.Name, src))
+	}
+	return fmt.Sprintf("%s{%s}", query.Page.Cursor.Name, strings.Join(fields, ", "))
+}
+
+// pageRequestMessage returns the <Name>Request message of the proto, with
+// the page_size and page_token fields. The fields aren't added to the params
+// message of the service, bound to the params of the <Name>Page method.
+func pageRequestMessage(m *metadata.Message) *metadata.Message {
+	msg := *m
+	msg.Fields = append(append([]*metadata.Field(nil), m.Fields...),
+		&metadata.Field{Name: "pageSize", Type: "int32"},
+		&metadata.Field{Name: "pageToken", Type: "string"},
+	)
+	return &msg
+}
+
+func pageProtoRPCs(s *pageService, serverType string) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	rpc := fmt.Sprintf("rpc %s(%sRequest) returns (%sResponse)", name, name, name)
+	if serverType != "grpc" {
+		return []string{rpc + " { }"}
+	}
+	// the response body is the whole response, with the next_page_token
+	svc := *s.Service
+	svc.Output = ""
+	res := []string{rpc + " {"}
+	for _, line := range svc.HttpOptions() {
+		res = append(res, "    "+line)
+	}
+	return append(res, "}")
+}
+
+// pageOutputGrpc fills res.List with the rows of the page. It's shared by the
+// grpc and connect servers.
+func pageOutputGrpc(s *pageService, enums serverEnums) []string {
+	adapter, ok := enums.adapter(s.Output)
+	if _, custom := s.Messages[converter.CanonicalName(s.Output)]; custom {
+		adapter, ok = "to"+converter.CanonicalName(s.Output), true
+	}
+	if !ok {
+		return []string{"res.List = result"}
+	}
+	return []string{
+		"for _, r := range result {",
+		fmt.Sprintf("res.List = append(res.List, %s(r))", adapter),
+		"}",
+	}
+}
+
+func pageInputGrpc(s *pageService, enums serverEnums, validators serverValidators) []string {
+	return append(enums.inputGrpc(s.Service, "req"), validators.inputGrpc(s.Service)...)
+}
+
+func pageHandlerTypes(s *pageService) []string {
+	res := make([]string, 0)
+	res = append(res, "type request struct {")
+	res = append(res, httpmetadata.RequestTypeAttributes(s.Service)...)
+	res = append(res, "PageSize int32 `form:\"page_size\" json:\"page_size\"`")
+	res = append(res, "PageToken string `form:\"page_token\" json:\"page_token\"`")
+	res = append(res, "}")
+	// the single column of a scalar row is the not null cursor, encoded as is
+	typ := strings.TrimPrefix(s.Output, "[]")
+	if attrs := httpmetadata.ResponseTypeAttributes(s.Service); len(attrs) > 0 {
+		res = append(res, "type response struct {")
+		res = append(res, attrs...)
+		res = append(res, "}")
+		typ = "response"
+	}
+	res = append(res, "type page struct {")
+	res = append(res, fmt.Sprintf("List []%s `json:\"list\"`", typ))
+	res = append(res, "NextPageToken string `json:\"next_page_token,omitempty\"`")
+	res = append(res, "}")
+	return res
+}
+
+// pageInputHttp converts the request to the query parameters and binds the
+// page_size and page_token query parameters of the GET and DELETE requests.
+func pageInputHttp(s *pageService) []string {
+	method := httpmetadata.HttpMethod(s.Service)
+	getOrDelete := method == "GET" || method == "DELETE"
+	res := httpmetadata.InputHttp(s.Service)
+	if s.EmptyInput() {
+		if getOrDelete {
+			res = append(res, "var req request")
+		} else {
+			res = append(res, "req, err := server.Decode[request](r)")
+			res = append(res, "if err != nil { http.Error(w, err.Error(), http.StatusUnprocessableEntity)")
+			res = append(res, "return }")
+		}
+	}
+	if getOrDelete {
+		res = append(res, httpmetadata.BindStringToSerializable("r.URL.Query().Get", "req", "PageSize", "int32")...)
+		res = append(res, httpmetadata.BindStringToSerializable("r.URL.Query().Get", "req", "PageToken", "string")...)
+	}
+	return res
+}
+
+// pageOutputHttp fills res.List with the rows of the page.
+func pageOutputHttp(s *pageService) []string {
+	m, ok := s.Messages[converter.CanonicalName(s.Output)]
+	if !ok {
+		return []string{fmt.Sprintf("res.List = append(make(%s, 0, len(result)), result...)", s.Output)}
+	}
+	res := make([]string, 0)
+	res = append(res, "res.List = make([]response, 0, len(result))")
+	res = append(res, "for _, r := range result {")
+	res = append(res, "var item response")
+	for _, f := range m.Fields {
+		res = append(res, httpmetadata.BindToSerializable("r", "item", converter.UpperFirstCharacter(f.Name), f.Type)...)
+	}
+	res = append(res, "res.List = append(res.List, item)")
+	res = append(res, "}")
+	return res
+}
+
+// pageApiService returns the service described by the OpenAPI, with the
+// page_size and page_token parameters at the endpoint of the page service.
+func pageApiService(s *pageService) *metadata.Service {
+	svc := *s.Service
+	svc.HttpSpecs = []metadata.HttpSpec{{Method: httpmetadata.HttpMethod(s.Service), Path: httpmetadata.HttpPath(s.Service)}}
+	svc.InputNames = append(append([]string(nil), s.InputNames...), "pageSize", "pageToken")
+	svc.InputTypes = append(append([]string(nil), s.InputTypes...), "int32", "string")
+	return &svc
+}
+
+// pageApiResponse describes the rows of the page and the next_page_token.
+func pageApiResponse(apiResponse func(*metadata.Service) []string, s *metadata.Service) []string {
+	res := []string{
+		"content:",
+		"  application/json:",
+		"    schema:",
+		"      type: object",
+		"      properties:",
+		"        list:",
+	}
+	lines := apiResponse(s)
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "schema:" {
+			continue
+		}
+		for _, item := range lines[i+1:] {
+			if strings.TrimSpace(item) == "format:" {
+				continue
+			}
+			res = append(res, "    "+item)
+		}
+		break
+	}
+	res = append(res, "        next_page_token:")
+	res = append(res, "          type: string")
+	return res
+}
@@ -0,0 +1,215 @@
+package golang
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+)
+
+const (
+	regionBegin = "// sqlc-gen-go-server:begin"
+	regionEnd   = "// sqlc-gen-go-server:end"
+)
+
+// addRegions adds the protected regions to the source of an editable Go
+// file: the imports region after the imports, the run region before the last
+// statement of the run function, where the server starts, and the custom
+// region at the end of the file.
+func addRegions(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	type insert struct {
+		offset int
+		text   string
+	}
+	inserts := make([]insert, 0)
+	importsEnd := f.Name.End()
+	for _, d := range f.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			importsEnd = gen.End()
+		}
+	}
+	inserts = append(inserts, insert{
+		offset: fset.Position(importsEnd).Offset,
+		text:   "\n\n" + region("imports", ""),
+	})
+	for _, d := range f.Decls {
+		fn, ok := d.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Name.Name != "run" || fn.Body == nil || len(fn.Body.List) == 0 {
+			continue
+		}
+		last := fset.Position(fn.Body.List[len(fn.Body.List)-1].Pos())
+		inserts = append(inserts, insert{
+			offset: last.Offset - (last.Column - 1),
+			text:   region("run", "\t"),
+		})
+	}
+	inserts = append(inserts, insert{
+		offset: len(src),
+		text:   "\n" + region("custom", ""),
+	})
+
+	sort.SliceStable(inserts, func(i, j int) bool { return inserts[i].offset > inserts[j].offset })
+	res := bytes.Clone(src)
+	for _, in := range inserts {
+		res = append(res[:in.offset], append([]byte(in.text), res[in.offset:]...)...)
+	}
+	return format.Source(res)
+}
+
+func region(name, indent string) string {
+	return fmt.Sprintf("%s%s %s\n%s%s %s\n", indent, regionBegin, name, indent, regionEnd, name)
+}
+
+// parseRegions returns the lines of each protected region of the source, in
+// order, by name.
+func parseRegions(src []byte) ([]string, map[string][]string, error) {
+	names := make([]string, 0)
+	regions := make(map[string][]string)
+	var current string
+	for i, line := range strings.Split(string(src), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, regionBegin):
+			name := strings.TrimSpace(strings.TrimPrefix(trimmed, regionBegin))
+			if current != "" {
+				return nil, nil, fmt.Errorf("line %d: region %q begins inside the region %q", i+1, name, current)
+			}
+			if name == "" {
+				return nil, nil, fmt.Errorf("line %d: region without name", i+1)
+			}
+			if _, ok := regions[name]; ok {
+				return nil, nil, fmt.Errorf("line %d: duplicate region %q", i+1, name)
+			}
+			current = name
+			names = append(names, name)
+			regions[name] = make([]string, 0)
+		case strings.HasPrefix(trimmed, regionEnd):
+			name := strings.TrimSpace(strings.TrimPrefix(trimmed, regionEnd))
+			if current == "" || (name != "" && name != current) {
+				return nil, nil, fmt.Errorf("line %d: end of the region %q without its beginning", i+1, name)
+			}
+			current = ""
+		case current != "":
+			regions[current] = append(regions[current], line)
+		}
+	}
+	if current != "" {
+		return nil, nil, fmt.Errorf("region %q without end", current)
+	}
+	return names, regions, nil
+}
+
+// mergeRegions replaces the protected regions of the generated source with
+// the regions of the previous source. The previous regions with content must
+// still be generated, or they would be lost.
+func mergeRegions(generated, previous []byte) ([]byte, error) {
+	prevNames, prevRegions, err := parseRegions(previous)
+	if err != nil {
+		return nil, fmt.Errorf("previous file: %w", err)
+	}
+	_, genRegions, err := parseRegions(generated)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range prevNames {
+		if _, ok := genRegions[name]; !ok && strings.TrimSpace(strings.Join(prevRegions[name], "")) != "" {
+			return nil, fmt.Errorf("the region %q isn't generated anymore, move its content to another region", name)
+		}
+	}
+
+	var b strings.Builder
+	var skip bool
+	for _, line := range strings.Split(string(generated), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, regionBegin):
+			b.WriteString(line + "\n")
+			name := strings.TrimSpace(strings.TrimPrefix(trimmed, regionBegin))
+			if lines, ok := prevRegions[name]; ok {
+				for _, l := range lines {
+					b.WriteString(l + "\n")
+				}
+				skip = true
+			}
+			continue
+		case strings.HasPrefix(trimmed, regionEnd):
+			skip = false
+		case skip:
+			continue
+		}
+		b.WriteString(line + "\n")
+	}
+	return format.Source([]byte(strings.TrimSuffix(b.String(), "\n")))
+}
+
+// regionsSnapshot holds the previous contents of the generated files, by path
+// relative to the root of the project: the inline snapshot, or the files of
+// the snapshot directory.
+type regionsSnapshot struct {
+	dir   string
+	files map[string]string
+}
+
+func newRegionsSnapshot(dir string, files map[string]string) (*regionsSnapshot, error) {
+	if len(files) > 0 {
+		return &regionsSnapshot{files: files}, nil
+	}
+	if dir == "" {
+		dir = "."
+	}
+	// the WASM plugin can't read the file system, it would silently lose the
+	// regions
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("snapshot_dir %q isn't readable, use the snapshot option with the WASM plugin: %w", dir, err)
+	}
+	return &regionsSnapshot{dir: dir}, nil
+}
+
+// previous returns the previous content of the file and if it exists.
+func (s *regionsSnapshot) previous(name string) ([]byte, bool, error) {
+	if s.files != nil {
+		content, ok := s.files[name]
+		return []byte(content), ok, nil
+	}
+	b, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	return b, err == nil, err
+}
+
+// protect adds the protected regions to the editable file, generated in the
+// out directory, and restores their previous content.
+func (s *regionsSnapshot) protect(out string, f *plugin.File) error {
+	name := filepath.ToSlash(filepath.Clean(filepath.Join(out, f.Name)))
+	src, err := addRegions(f.Contents)
+	if err != nil {
+		return fmt.Errorf("protected regions of %s: %w", name, err)
+	}
+	previous, ok, err := s.previous(name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if src, err = mergeRegions(src, previous); err != nil {
+			return fmt.Errorf("protected regions of %s: %w", name, err)
+		}
+	}
+	f.Contents = src
+	return nil
+}
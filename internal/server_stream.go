@@ -0,0 +1,152 @@
+package golang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// streamService exposes a :many query annotated with "-- stream:". The rows
+// are sent as they are read from the database, one response per row, instead
+// of being loaded in a list.
+type streamService struct {
+	*metadata.Service
+	// RowType is the type of each row passed to the <Name>Stream callback.
+	RowType string
+}
+
+// Format returns the http format of the stream: ndjson (default) or sse.
+func (s *streamService) Format() string {
+	return streamHttpFormat(s.Service)
+}
+
+func streamHttpFormat(s *metadata.Service) string {
+	for _, format := range s.CustomSpecs["stream"] {
+		if strings.TrimSpace(format) == "sse" {
+			return "sse"
+		}
+	}
+	return "ndjson"
+}
+
+// isStream reports if the service was created from a stream service, the
+// funcs shared with the regular services use it to describe the stream.
+func isStream(s *metadata.Service) bool {
+	_, ok := s.CustomSpecs["stream"]
+	return ok
+}
+
+func streamProtoRPCs(s *streamService, serverType string) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	rpc := fmt.Sprintf("rpc %s(%sRequest) returns (stream %sResponse)", name, name, name)
+	if serverType != "grpc" {
+		return []string{rpc + " { }"}
+	}
+	res := []string{rpc + " {"}
+	for _, line := range s.HttpOptions() {
+		res = append(res, "    "+line)
+	}
+	return append(res, "}")
+}
+
+// streamInputGrpc converts the request to the query parameters. It's shared
+// by the grpc and connect servers, whose stream methods only return an error.
+func streamInputGrpc(s *streamService, enums serverEnums, validators serverValidators) []string {
+	res := append(enums.inputGrpc(s.Service, "req"), validators.inputGrpc(s.Service)...)
+	for i, line := range res {
+		res[i] = strings.ReplaceAll(line, "return nil, ", "return ")
+	}
+	return res
+}
+
+// streamSendGrpc sends the row to the client. It's shared by the grpc and
+// connect servers.
+func streamSendGrpc(s *streamService, enums serverEnums) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	if s.HasCustomOutput() {
+		typ := converter.CanonicalName(s.Output)
+		return []string{fmt.Sprintf("return stream.Send(&pb.%sResponse{%s: to%s(row)})", name, converter.CamelCaseProto(typ), typ)}
+	}
+	if adapter, ok := enums.adapter(s.Output); ok {
+		return []string{fmt.Sprintf("return stream.Send(&pb.%sResponse{Value: %s(row)})", name, adapter)}
+	}
+	return []string{fmt.Sprintf("return stream.Send(&pb.%sResponse{Value: row})", name)}
+}
+
+// streamOutputHttp encodes the row as an item of the stream.
+func streamOutputHttp(s *streamService) []string {
+	m, ok := s.Messages[converter.CanonicalName(s.Output)]
+	if !ok {
+		return []string{"return stream.Encode(row)"}
+	}
+	res := make([]string, 0)
+	res = append(res, "var item response")
+	for _, f := range m.Fields {
+		res = append(res, httpmetadata.BindToSerializable("row", "item", converter.UpperFirstCharacter(f.Name), f.Type)...)
+	}
+	res = append(res, "return stream.Encode(item)")
+	return res
+}
+
+// streamApiResponse describes the items of the stream, with the media type of
+// the http format.
+func streamApiResponse(apiResponse func(*metadata.Service) []string, s *metadata.Service) []string {
+	svc := *s
+	svc.Output = "[]" + s.Output
+	lines := apiResponse(&svc)
+	mediaType := "application/x-ndjson"
+	if streamHttpFormat(s) == "sse" {
+		mediaType = "text/event-stream"
+	}
+	res := []string{"content:", fmt.Sprintf("  %s:", mediaType), "    schema:"}
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "items:" {
+			continue
+		}
+		for _, item := range lines[i+1:] {
+			if strings.TrimSpace(item) == "format:" {
+				continue
+			}
+			res = append(res, strings.Replace(item, "  ", "", 1))
+		}
+		break
+	}
+	return res
+}
+
+// apiPackage returns the package with the stream and page services, which are
+// described by the OpenAPI like the regular services.
+func (pkg *serverPackage) apiPackage() *metadata.Package {
+	if len(pkg.StreamServices) == 0 && len(pkg.PageServices) == 0 {
+		return pkg.metadataPackage
+	}
+	apiPkg := *pkg.metadataPackage
+	apiPkg.Services = make([]*metadata.Service, 0, len(pkg.Services)+len(pkg.StreamServices)+len(pkg.PageServices))
+	apiPkg.Services = append(apiPkg.Services, pkg.Services...)
+	for _, s := range pkg.StreamServices {
+		apiPkg.Services = append(apiPkg.Services, s.Service)
+	}
+	for _, s := range pkg.PageServices {
+		apiPkg.Services = append(apiPkg.Services, pageApiService(s))
+	}
+	return &apiPkg
+}
+
+// apiDefinition returns the definition described by the OpenAPI.
+func (pkg *serverPackage) apiDefinition(def *metadata.Definition) *metadata.Definition {
+	if len(pkg.StreamServices) == 0 && len(pkg.PageServices) == 0 {
+		return def
+	}
+	apiDef := *def
+	apiDef.Packages = make([]*metadata.Package, len(def.Packages))
+	for i, p := range def.Packages {
+		if p == pkg.metadataPackage {
+			p = pkg.apiPackage()
+		}
+		apiDef.Packages[i] = p
+	}
+	return &apiDef
+}
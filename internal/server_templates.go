@@ -0,0 +1,234 @@
+package golang
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serverTemplates holds the templates of the plugin, organized by server type,
+// that are added to or replace the templates of the sqlc-grpc, sqlc-connect
+// and sqlc-http projects.
+//
+//go:embed server_templates
+var serverTemplates embed.FS
+
+func serverTemplatesFS(serverType string, base fs.FS) (fs.FS, error) {
+	top, err := fs.Sub(serverTemplates, "server_templates/"+serverType)
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: top, base: base}, nil
+}
+
+// registryTemplatesFS adds the registry of the grpc and http servers starting
+// the auth, tenant, outbox and webhook templates. The other server types
+// have their own registry.
+func registryTemplatesFS(serverType string, base fs.FS) (fs.FS, error) {
+	if serverType != "grpc" && serverType != "http" {
+		return base, nil
+	}
+	top, err := fs.Sub(serverTemplates, "server_templates/registry/"+serverType)
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: top, base: base}, nil
+}
+
+// overlayFS reads the files of top, falling back to base.
+type overlayFS struct {
+	top  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if err == nil {
+		info, err := f.Stat()
+		if err == nil && !info.IsDir() {
+			return f, nil
+		}
+		f.Close()
+	}
+	return o.base.Open(name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(o.base, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	topEntries, topErr := fs.ReadDir(o.top, name)
+	if topErr != nil {
+		if errors.Is(topErr, fs.ErrNotExist) && err == nil {
+			return entries, nil
+		}
+		return nil, topErr
+	}
+	byName := make(map[string]fs.DirEntry, len(entries)+len(topEntries))
+	for _, e := range entries {
+		byName[e.Name()] = e
+	}
+	for _, e := range topEntries {
+		byName[e.Name()] = e
+	}
+	res := make([]fs.DirEntry, 0, len(byName))
+	for _, e := range byName {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
+
+// excludeFS hides the named files of base.
+type excludeFS struct {
+	base  fs.FS
+	names []string
+}
+
+func (e excludeFS) Open(name string) (fs.File, error) {
+	if slices.Contains(e.names, name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return e.base.Open(name)
+}
+
+func (e excludeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(e.base, name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !slices.Contains(e.names, path.Join(name, entry.Name())) {
+			res = append(res, entry)
+		}
+	}
+	return res, nil
+}
+
+// customTemplatesFS adds the templates of the template_dir and templates
+// options to the templates of the server, the inline templates taking
+// precedence. A template named like an embedded one, with or without the
+// .tmpl suffix, replaces it. The other templates are added, rendered like the
+// embedded templates of the same name.
+func customTemplatesFS(base fs.FS, dir string, templates map[string]string) (fs.FS, error) {
+	custom := make(map[string]string)
+	if dir != "" {
+		dirFS := os.DirFS(dir)
+		err := fs.WalkDir(dirFS, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && name != "." {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			b, err := fs.ReadFile(dirFS, name)
+			if err != nil {
+				return err
+			}
+			custom[name] = string(b)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("template_dir: %w", err)
+		}
+	}
+	for name, tmpl := range templates {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("invalid template name %q: use a relative path like main.go.tmpl", name)
+		}
+		custom[name] = tmpl
+	}
+	top := make(templateFS, len(custom))
+	for name, tmpl := range custom {
+		if _, err := fs.Stat(base, name); err != nil && !strings.HasSuffix(name, ".tmpl") {
+			if _, err := fs.Stat(base, name+".tmpl"); err == nil {
+				name += ".tmpl"
+			}
+		}
+		top[name] = tmpl
+	}
+	return overlayFS{top: top, base: base}, nil
+}
+
+// templateFS holds the custom templates by path. Its directories are only
+// listed, the overlayFS opening them from the base.
+type templateFS map[string]string
+
+func (t templateFS) Open(name string) (fs.File, error) {
+	tmpl, ok := t[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return templateFile{Reader: strings.NewReader(tmpl), info: templateInfo{name: path.Base(name), size: int64(len(tmpl))}}, nil
+}
+
+func (t templateFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	byName := make(map[string]fs.DirEntry)
+	for file, tmpl := range t {
+		rest, ok := strings.CutPrefix(file, prefix)
+		if !ok {
+			continue
+		}
+		if dir, _, ok := strings.Cut(rest, "/"); ok {
+			byName[dir] = fs.FileInfoToDirEntry(templateInfo{name: dir, dir: true})
+		} else {
+			byName[rest] = fs.FileInfoToDirEntry(templateInfo{name: rest, size: int64(len(tmpl))})
+		}
+	}
+	if len(byName) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	res := make([]fs.DirEntry, 0, len(byName))
+	for _, e := range byName {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
+
+type templateFile struct {
+	*strings.Reader
+	info templateInfo
+}
+
+func (f templateFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f templateFile) Close() error { return nil }
+
+type templateInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i templateInfo) Name() string       { return i.name }
+func (i templateInfo) Size() int64        { return i.size }
+func (i templateInfo) ModTime() time.Time { return time.Time{} }
+func (i templateInfo) IsDir() bool        { return i.dir }
+func (i templateInfo) Sys() any           { return nil }
+
+func (i templateInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
@@ -0,0 +1,297 @@
+package golang
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sqlc-dev/sqlc-gen-go/internal/opts"
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// serverTenant scopes the requests to the tenant of the caller, read from a
+// header or from a claim of the token by the generated middleware.
+type serverTenant struct {
+	// Header is the header with the tenant, like X-Tenant-ID.
+	Header string
+	// Claim is the claim of the token with the tenant, like tenant_id.
+	Claim string
+	// Setting is the Postgres setting, like app.tenant_id, set in the
+	// transaction of each request for the row level security policies.
+	Setting string
+	// Param is the query parameter filled with the tenant.
+	Param string
+	// Type is the Go type of the tenant, the type of the params.
+	Type string
+	// Fields are the tenant fields of the params structs, by service name.
+	Fields map[string]string
+}
+
+// settingName matches the names of the custom settings of Postgres, which
+// have a prefix.
+var settingName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\.[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseTenant returns the tenant scoping of the options, or nil if the
+// requests aren't scoped.
+func parseTenant(options *opts.Options, serverType, engine string, auth authMethods, params map[string]*metadata.Field) (*serverTenant, error) {
+	if options.Tenant == "" {
+		if options.TenantSetting != "" || options.TenantParam != "" {
+			return nil, fmt.Errorf("the tenant_setting and tenant_param options require the tenant option")
+		}
+		return nil, nil
+	}
+	var t serverTenant
+	source, name, _ := strings.Cut(options.Tenant, ":")
+	switch name = strings.TrimSpace(name); {
+	case source == "header" && name != "":
+		t.Header = name
+	case source == "claim" && name != "":
+		t.Claim = name
+	default:
+		return nil, fmt.Errorf("invalid tenant %q. Use 'header:<name>' or 'claim:<name>'", options.Tenant)
+	}
+	if serverType != "http" && serverType != "grpc" && serverType != "connect" {
+		return nil, fmt.Errorf("the tenant option isn't supported by the %s server type. Choose 'http', 'grpc' or 'connect'", serverType)
+	}
+	if t.Claim != "" && !auth.JWT {
+		return nil, fmt.Errorf("the tenant claim requires the jwt auth method")
+	}
+	if t.Header != "" && !auth.enabled() {
+		// the header is chosen by the client, it's checked against the
+		// tenants of the authenticated caller
+		return nil, fmt.Errorf("the tenant header requires the auth option")
+	}
+	if options.TenantSetting == "" && options.TenantParam == "" {
+		return nil, fmt.Errorf("the tenant option requires the tenant_setting or the tenant_param option")
+	}
+	if t.Setting = options.TenantSetting; t.Setting != "" {
+		if engine != "postgresql" {
+			return nil, fmt.Errorf("the tenant_setting option requires the postgresql engine")
+		}
+		if !settingName.MatchString(t.Setting) {
+			return nil, fmt.Errorf("invalid tenant_setting %q, use a prefixed name like app.tenant_id", t.Setting)
+		}
+		if options.EmitMethodsWithDbArgument || options.EmitPreparedQueries {
+			// the queries wouldn't run in the transaction of the request
+			return nil, fmt.Errorf("the tenant_setting option can't be used with emit_methods_with_db_argument or emit_prepared_queries")
+		}
+	}
+	t.Param = options.TenantParam
+	t.Type = "string"
+	t.Fields = make(map[string]string, len(params))
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		f := params[name]
+		switch f.Type {
+		case "string", "int32", "int64", "uuid.UUID", "pgtype.UUID":
+		default:
+			return nil, fmt.Errorf("query %s: the tenant param has the unsupported type %s", name, f.Type)
+		}
+		if i > 0 && f.Type != t.Type {
+			return nil, fmt.Errorf("the tenant params have different types, %s and %s", t.Type, f.Type)
+		}
+		t.Type = f.Type
+		t.Fields[name] = f.Name
+	}
+	return &t, nil
+}
+
+// the scopes of the services, read by the middleware of the tenant
+const (
+	// tenantCaller services are scoped to a tenant of the authenticated caller
+	tenantCaller = "caller"
+	// tenantPublic services are called without credentials, scoped to the
+	// tenant of the header
+	tenantPublic = "public"
+	// tenantNone services, annotated with "tenant: none", are called without
+	// a tenant
+	tenantNone = "none"
+)
+
+// scope returns the scope of the service, or of the transaction, with the
+// name. A transaction is scoped if one of its steps is, and public if all
+// its steps are.
+func (t *serverTenant) scope(pkg *serverPackage, required bool, name string) string {
+	names := pkg.steps(name)
+	scoped := slices.ContainsFunc(names, func(name string) bool {
+		_, ok := t.Fields[name]
+		return ok || t.Setting != "" && !slices.Contains(pkg.Unscoped, name)
+	})
+	switch {
+	case !scoped:
+		return tenantNone
+	case slices.ContainsFunc(names, func(name string) bool { return pkg.Auth.rule(name, required) != nil }):
+		return tenantCaller
+	}
+	return tenantPublic
+}
+
+// checkPublic returns an error if a public service is scoped to the tenant
+// of a claim, which the callers without credentials don't have.
+func (t *serverTenant) checkPublic(pkg *serverPackage, required bool) error {
+	if t == nil || t.Claim == "" {
+		return nil
+	}
+	for _, name := range pkg.serviceNames() {
+		if t.scope(pkg, required, name) == tenantPublic {
+			return fmt.Errorf("service %s: the public services can't be scoped to the tenant of the %s claim. Add an auth comment or annotate the query with \"-- tenant: none\"", name, t.Claim)
+		}
+	}
+	return nil
+}
+
+// tenantMethod is the scope of a grpc method, by full method name, read by
+// the interceptors of the tenant.
+type tenantMethod struct {
+	Name  string
+	Scope string
+}
+
+// methods returns the grpc methods of the package not scoped to a tenant of
+// the caller.
+func (t *serverTenant) methods(pkg *serverPackage, required bool) []tenantMethod {
+	service := "/" + converter.ToSnakeCase(pkg.Package) + ".v1." + converter.ToPascalCase(pkg.Package) + "Service/"
+	res := make([]tenantMethod, 0)
+	for _, name := range pkg.serviceNames() {
+		if scope := t.scope(pkg, required, name); scope != tenantCaller {
+			res = append(res, tenantMethod{Name: service + name, Scope: scope})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// tenantTemplatesFS adds the tenant package and the middleware of the server
+// type to the templates.
+func tenantTemplatesFS(serverType string, base fs.FS) (fs.FS, error) {
+	common, err := fs.Sub(serverTemplates, "server_templates/tenant/common")
+	if err != nil {
+		return nil, err
+	}
+	top, err := fs.Sub(serverTemplates, "server_templates/tenant/"+serverType)
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: overlayFS{top: top, base: common}, base: base}, nil
+}
+
+// tenantFuncs adds the Tenant, TenantEnabled and TenantOnly functions,
+// describing the scoping to the templates, the TenantWrap and TenantMethods
+// functions, scoping the http handlers and the grpc methods, and fills the
+// tenant field of the params after the input of the services. The http
+// services whose params have only the tenant don't read the request.
+func tenantFuncs(funcs template.FuncMap, serverType string, t *serverTenant, pkg *serverPackage, required bool) template.FuncMap {
+	res := maps.Clone(funcs)
+	res["TenantEnabled"] = func() bool { return t != nil }
+	res["TenantWrap"] = func(name, handler string) string {
+		if t == nil {
+			return handler
+		}
+		switch t.scope(pkg, required, name) {
+		case tenantNone:
+			return handler
+		case tenantPublic:
+			return "tenant.PublicMiddleware(" + handler + ")"
+		}
+		return "tenant.Middleware(" + handler + ")"
+	}
+	res["TenantMethods"] = func() []tenantMethod {
+		if t == nil {
+			return nil
+		}
+		return t.methods(pkg, required)
+	}
+	res["Tenant"] = func() *serverTenant {
+		if t == nil {
+			return &serverTenant{}
+		}
+		return t
+	}
+	// the params of the services with only the tenant have no request fields
+	tenantOnly := func(s *metadata.Service) bool {
+		if _, ok := t.Fields[s.Name]; !ok || s.EmptyInput() {
+			return false
+		}
+		m := s.Messages[converter.CanonicalName(s.InputTypes[0])]
+		return m != nil && len(m.Fields) == 0
+	}
+	res["TenantOnly"] = func(s *metadata.Service) bool {
+		return t != nil && tenantOnly(s)
+	}
+	if t == nil || len(t.Fields) == 0 {
+		return res
+	}
+	fill := func(s *metadata.Service, ctx string) []string {
+		field, ok := t.Fields[s.Name]
+		if !ok {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s.%s = tenant.ID(%s)", s.InputNames[0], field, ctx)}
+	}
+	// the context of the requests, and of the grpc streams
+	ctx, streamCtx := "ctx", "ctx"
+	switch serverType {
+	case "http":
+		ctx, streamCtx = "r.Context()", "r.Context()"
+	case "grpc":
+		streamCtx = "stream.Context()"
+	}
+	if input, ok := funcs["Input"].(func(*metadata.Service) []string); ok {
+		res["Input"] = func(s *metadata.Service) []string {
+			lines := input(s)
+			if serverType == "http" && tenantOnly(s) {
+				lines = skipRequestHttp(lines)
+			}
+			return append(lines, fill(s, ctx)...)
+		}
+	}
+	if input, ok := funcs["BatchInput"].(func(*batchService) []string); ok {
+		res["BatchInput"] = func(s *batchService) []string {
+			return append(input(s), fill(s.Service, ctx)...)
+		}
+	}
+	if input, ok := funcs["TxInput"].(func(*transactionStep) []string); ok {
+		res["TxInput"] = func(s *transactionStep) []string {
+			return append(input(s), fill(s.Service, "ctx")...)
+		}
+	}
+	if input, ok := funcs["PageInput"].(func(*pageService) []string); ok {
+		res["PageInput"] = func(s *pageService) []string {
+			return append(input(s), fill(s.Service, ctx)...)
+		}
+	}
+	if input, ok := funcs["StreamInput"].(func(*streamService) []string); ok {
+		res["StreamInput"] = func(s *streamService) []string {
+			return append(input(s), fill(s.Service, streamCtx)...)
+		}
+	}
+	if input, ok := funcs["CopyFromInput"].(func(*copyFromService) []string); ok {
+		res["CopyFromInput"] = func(s *copyFromService) []string {
+			return append(input(s), fill(s.Service, streamCtx)...)
+		}
+	}
+	return res
+}
+
+// skipRequestHttp removes the request, without fields, from the input of an
+// http handler. The decoding of the body is replaced by the declaration of
+// the err assigned by the call of the query.
+func skipRequestHttp(lines []string) []string {
+	switch {
+	case len(lines) > 0 && lines[0] == "var req request":
+		return lines[1:]
+	case len(lines) > 2 && strings.HasPrefix(lines[0], "req, err := server.Decode"):
+		return append([]string{"var err error"}, lines[3:]...)
+	}
+	return lines
+}
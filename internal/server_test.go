@@ -0,0 +1,2428 @@
+package golang
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the server tests")
+
+var (
+	authorsTable = &plugin.Identifier{Name: "authors"}
+
+	authorsID   = &plugin.Column{Name: "id", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "bigint"}}
+	authorsName = &plugin.Column{Name: "name", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "text"}}
+	authorsBio  = &plugin.Column{Name: "bio", Table: authorsTable, Type: &plugin.Identifier{Name: "text"}}
+)
+
+func authorsRequest(t *testing.T, engine string, options map[string]any, queries ...*plugin.Query) *plugin.GenerateRequest {
+	t.Helper()
+	pluginOptions := map[string]any{
+		"package": "authors",
+		"module":  "example.com/authors",
+	}
+	for k, v := range options {
+		pluginOptions[k] = v
+	}
+	b, err := json.Marshal(pluginOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &plugin.GenerateRequest{
+		Settings: &plugin.Settings{
+			Engine: engine,
+			Codegen: &plugin.Codegen{
+				Out: "internal/authors",
+			},
+		},
+		Catalog: &plugin.Catalog{
+			DefaultSchema: "public",
+			Schemas: []*plugin.Schema{
+				{
+					Name: "public",
+					Tables: []*plugin.Table{
+						{
+							Rel:     authorsTable,
+							Columns: []*plugin.Column{authorsID, authorsName, authorsBio},
+						},
+					},
+				},
+			},
+		},
+		Queries:       queries,
+		PluginOptions: b,
+	}
+}
+
+func generateServerFiles(t *testing.T, req *plugin.GenerateRequest) map[string]string {
+	t.Helper()
+	resp, err := Generate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range resp.Files {
+		files[filepath.ToSlash(f.Name)] = string(f.Contents)
+	}
+	return files
+}
+
+func assertGolden(t *testing.T, dir string, files map[string]string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		got, ok := files[name]
+		if !ok {
+			t.Errorf("file %q not generated", name)
+			continue
+		}
+		goldenFile := filepath.Join("testdata", "server", dir, filepath.FromSlash(strings.ReplaceAll(name, "../", ""))+".golden")
+		if *update {
+			if err := os.MkdirAll(filepath.Dir(goldenFile), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(goldenFile, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(goldenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(want), got); diff != "" {
+			t.Errorf("%s differs from golden file (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestServerExecCountResponses(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":execlastid",
+			Text:     "INSERT INTO authors (name, bio) VALUES (?, ?)",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+		{
+			Name:     "UpdateAuthorBio",
+			Cmd:      ":execrows",
+			Text:     "UPDATE authors SET bio = ? WHERE id = ?",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsBio},
+				{Number: 2, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"proto/authors/v1/authors.proto", "service.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"proto/authors/v1/authors.proto", "service.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"openapi.yml", "service.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "mysql", map[string]any{"server_type": tc.serverType}, queries...)
+			files := generateServerFiles(t, req)
+			names := make([]string, 0, len(tc.files))
+			for _, name := range tc.files {
+				if name != "service.go" {
+					name = "../../" + name
+				}
+				names = append(names, name)
+			}
+			assertGolden(t, filepath.Join("exec_count", tc.serverType), files, names...)
+		})
+	}
+}
+
+func TestServerRegistryPackages(t *testing.T) {
+	query := &plugin.Query{
+		Name:     "ListAuthors",
+		Cmd:      ":many",
+		Text:     "SELECT id, name, bio FROM authors ORDER BY name",
+		Filename: "query.sql",
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+	}
+	packages := []map[string]any{
+		{"out": "internal/books"},
+		{"package": "authors", "out": "internal/authors"},
+		{"package": "audit", "out": "internal/audit", "emit_methods_with_db_argument": true},
+	}
+	for _, serverType := range []string{"grpc", "connect", "http"} {
+		t.Run(serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type": serverType,
+				"sql_package": "pgx/v5",
+				"packages":    packages,
+			}, query)
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("registry", serverType), files, "../../registry.go", "../../go.mod")
+		})
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:     "CreateAuthors",
+			Cmd:      ":batchexec",
+			Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2)",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+		{
+			Name:     "GetAuthors",
+			Cmd:      ":batchone",
+			Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ListAuthorsByName",
+			Cmd:      ":batchmany",
+			Text:     "SELECT id, name, bio FROM authors WHERE name = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.batch.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.batch.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"../../openapi.yml", "routes.go", "service.batch.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}, queries...)
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("batch", tc.serverType), files, tc.files...)
+		})
+	}
+}
+
+func TestServerCopyFrom(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:            "LoadAuthors",
+			Cmd:             ":copyfrom",
+			Text:            "INSERT INTO authors (name, bio) VALUES ($1, $2)",
+			Filename:        "query.sql",
+			InsertIntoTable: authorsTable,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.copyfrom.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.copyfrom.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"../../openapi.yml", "routes.go", "service.copyfrom.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}, queries...)
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("copyfrom", tc.serverType), files, tc.files...)
+		})
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:     "ExportAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors ORDER BY id",
+			Filename: "query.sql",
+			Comments: []string{" stream:"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:     "SearchAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors WHERE name = $1",
+			Filename: "query.sql",
+			Comments: []string{" stream: sse", " http: POST /authors/search"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.stream.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.stream.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"../../openapi.yml", "routes.go", "service.stream.go", "query.sql.go", "querier.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type":    tc.serverType,
+				"sql_package":    "pgx/v5",
+				"emit_interface": true,
+			}, queries...)
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("stream", tc.serverType), files, tc.files...)
+		})
+	}
+}
+
+func TestServerPage(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors",
+			Filename: "query.sql",
+			Comments: []string{" paginate: id"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:     "ListAuthorsByBio",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors WHERE bio = $1 AND name <> $2",
+			Filename: "query.sql",
+			Comments: []string{" paginate: name desc, id desc"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsBio},
+				{Number: 2, Column: authorsName},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.page.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"../../proto/authors/v1/authors.proto", "service.page.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"../../openapi.yml", "routes.go", "service.page.go", "query.sql.go", "querier.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type":    tc.serverType,
+				"sql_package":    "pgx/v5",
+				"emit_interface": true,
+			}, queries...)
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("page", tc.serverType), files, tc.files...)
+		})
+	}
+}
+
+func TestServerPageInvalidColumn(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		columns []*plugin.Column
+	}{
+		{name: "not returned", columns: []*plugin.Column{authorsID, authorsName}},
+		{name: "nullable", columns: []*plugin.Column{authorsID, authorsName, authorsBio}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type": "http",
+				"sql_package": "pgx/v5",
+			}, &plugin.Query{
+				Name:     "ListAuthors",
+				Cmd:      ":many",
+				Text:     "SELECT id, name, bio FROM authors",
+				Filename: "query.sql",
+				Comments: []string{" paginate: bio"},
+				Columns:  tc.columns,
+			})
+			if _, err := Generate(context.Background(), req); err == nil {
+				t.Fatalf("expected an error for a paginate column %s", tc.name)
+			}
+		})
+	}
+}
+
+func TestServerEnums(t *testing.T) {
+	status := &plugin.Column{Name: "status", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	previousStatus := &plugin.Column{Name: "previous_status", Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	queries := []*plugin.Query{
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, status, previous_status) VALUES ($1, $2, $3) RETURNING id, name, status, previous_status",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, status, previousStatus},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: status},
+				{Number: 3, Column: previousStatus},
+			},
+		},
+		{
+			Name:     "ListAuthorsByStatus",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, status, previous_status FROM authors WHERE status = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, status, previousStatus},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: status},
+			},
+		},
+		{
+			Name:     "GetAuthorPreviousStatus",
+			Cmd:      ":one",
+			Text:     "SELECT previous_status FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{previousStatus},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"../../proto/authors/v1/authors.proto", "adapters.go", "service.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"adapters.go", "service.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"../../openapi.yml", "service.go", "models.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}, queries...)
+			schema := req.Catalog.Schemas[0]
+			schema.Tables[0].Columns = []*plugin.Column{authorsID, authorsName, status, previousStatus}
+			schema.Enums = []*plugin.Enum{{Name: "author_status", Vals: []string{"active", "on-leave", "retired"}}}
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("enums", tc.serverType), files, tc.files...)
+		})
+	}
+}
+
+func TestServerValidation(t *testing.T) {
+	name := &plugin.Column{Name: "name", NotNull: true, Length: 100, Table: authorsTable, Type: &plugin.Identifier{Schema: "pg_catalog", Name: "varchar"}}
+	bio := &plugin.Column{Name: "bio", Length: 500, Table: authorsTable, Type: &plugin.Identifier{Schema: "pg_catalog", Name: "varchar"}}
+	tags := &plugin.Column{Name: "tags", NotNull: true, IsArray: true, ArrayDims: 1, Length: 20, Table: authorsTable, Type: &plugin.Identifier{Schema: "pg_catalog", Name: "varchar"}}
+	status := &plugin.Column{Name: "status", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	columns := []*plugin.Column{authorsID, name, bio, tags, status}
+	queries := []*plugin.Query{
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio, tags, status) VALUES ($1, $2, $3, $4) RETURNING id, name, bio, tags, status",
+			Filename: "query.sql",
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: name},
+				{Number: 2, Column: bio},
+				{Number: 3, Column: tags},
+				{Number: 4, Column: status},
+			},
+		},
+		{
+			Name:     "ListAuthorsByName",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, tags, status FROM authors WHERE name = $1",
+			Filename: "query.sql",
+			Comments: []string{" http: GET /authors"},
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: name},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "grpc",
+			files:      []string{"service.go", "service.validate.go", "../../internal/validation/validation.go"},
+		},
+		{
+			serverType: "connect",
+			files:      []string{"service.go", "../../internal/validation/validation.go"},
+		},
+		{
+			serverType: "http",
+			files:      []string{"../../openapi.yml", "service.go", "service.validate.go", "../../internal/validation/validation.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}, queries...)
+			schema := req.Catalog.Schemas[0]
+			schema.Tables[0].Columns = columns
+			schema.Enums = []*plugin.Enum{{Name: "author_status", Vals: []string{"active", "retired"}}}
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("validation", tc.serverType), files, tc.files...)
+		})
+	}
+}
+
+func TestServerDBErrors(t *testing.T) {
+	query := &plugin.Query{
+		Name:     "GetAuthor",
+		Cmd:      ":one",
+		Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsID},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		engine  string
+		options map[string]any
+		files   []string
+	}{
+		{
+			name:    "grpc-pgx",
+			engine:  "postgresql",
+			options: map[string]any{"server_type": "grpc", "sql_package": "pgx/v5"},
+			files:   []string{"../../internal/dberrors/dberrors.go", "../../internal/dberrors/kind.go", "../../internal/server/error_mapper.go"},
+		},
+		{
+			name:    "connect-libpq",
+			engine:  "postgresql",
+			options: map[string]any{"server_type": "connect"},
+			files:   []string{"../../internal/dberrors/dberrors.go", "../../internal/dberrors/kind.go", "../../registry.go"},
+		},
+		{
+			name:    "http-mysql",
+			engine:  "mysql",
+			options: map[string]any{"server_type": "http"},
+			files:   []string{"../../internal/dberrors/dberrors.go", "../../internal/dberrors/kind.go", "service.go", "../../go.mod"},
+		},
+		{
+			name:    "http-sqlite",
+			engine:  "sqlite",
+			options: map[string]any{"server_type": "http"},
+			files:   []string{"../../internal/dberrors/dberrors.go", "../../internal/dberrors/kind.go"},
+		},
+		{
+			name:    "http-sqlite3",
+			engine:  "sqlite",
+			options: map[string]any{"server_type": "http", "litestream": true},
+			files:   []string{"../../internal/dberrors/dberrors.go", "../../internal/dberrors/kind.go"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := authorsRequest(t, tc.engine, tc.options, query)
+			files := generateServerFiles(t, req)
+			assertGolden(t, filepath.Join("dberrors", tc.name), files, tc.files...)
+		})
+	}
+	t.Run("pgx/v4", func(t *testing.T) {
+		req := authorsRequest(t, "postgresql", map[string]any{"server_type": "http", "sql_package": "pgx/v4"}, query)
+		if _, err := Generate(context.Background(), req); err == nil {
+			t.Fatal("expected an error for the pgx/v4 sql_package")
+		}
+	})
+}
+
+func TestServerGraphql(t *testing.T) {
+	status := &plugin.Column{Name: "status", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	columns := []*plugin.Column{authorsID, authorsName, authorsBio, status}
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio, status FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status FROM authors WHERE status = $1",
+			Filename: "query.sql",
+			Comments: []string{" paginate: id"},
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: status},
+			},
+		},
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio, status) VALUES ($1, $2, $3) RETURNING id, name, bio, status",
+			Filename: "query.sql",
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+				{Number: 3, Column: status},
+			},
+		},
+		{
+			Name:     "UpdateAuthorBio",
+			Cmd:      ":execrows",
+			Text:     "UPDATE authors SET bio = $1 WHERE id = $2",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsBio},
+				{Number: 2, Column: authorsID},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		options map[string]any
+		files   []string
+	}{
+		{
+			name:    "pgx",
+			options: map[string]any{"server_type": "graphql", "sql_package": "pgx/v5"},
+			files:   []string{"schema.graphql", "service.go", "service.page.go", "adapters.go", "../../registry.go", "../../go.mod"},
+		},
+		{
+			name: "database-sql",
+			options: map[string]any{
+				"server_type":                   "graphql",
+				"emit_methods_with_db_argument": true,
+				"emit_result_struct_pointers":   true,
+				"tracing":                       true,
+			},
+			files: []string{"service.go", "../../registry.go", "../../internal/dberrors/dberrors.go", "../../internal/server/graphql.go"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := authorsRequest(t, "postgresql", tc.options, queries...)
+			schema := req.Catalog.Schemas[0]
+			schema.Tables[0].Columns = columns
+			schema.Enums = []*plugin.Enum{{Name: "author_status", Vals: []string{"active", "on-leave", "retired"}}}
+			files := generateServerFiles(t, req)
+			for _, name := range []string{"routes.go", "../../openapi.yml"} {
+				if _, ok := files[name]; ok {
+					t.Errorf("unexpected file %q", name)
+				}
+			}
+			assertGolden(t, filepath.Join("graphql", tc.name), files, tc.files...)
+		})
+	}
+}
+
+func TestServerJsonrpc(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Comments: []string{" stream:"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:     "PageAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors WHERE name <> $1",
+			Filename: "query.sql",
+			Comments: []string{" paginate: id"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+			},
+		},
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+		{
+			Name:     "UpdateAuthorBio",
+			Cmd:      ":execrows",
+			Text:     "UPDATE authors SET bio = $1 WHERE id = $2",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsBio},
+				{Number: 2, Column: authorsID},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		options map[string]any
+		queries []*plugin.Query
+		files   []string
+	}{
+		{
+			name:    "pgx",
+			options: map[string]any{"server_type": "jsonrpc", "sql_package": "pgx/v5"},
+			queries: append(queries, &plugin.Query{
+				Name:     "CreateAuthors",
+				Cmd:      ":batchexec",
+				Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2)",
+				Filename: "query.sql",
+				Params: []*plugin.Parameter{
+					{Number: 1, Column: authorsName},
+					{Number: 2, Column: authorsBio},
+				},
+			}),
+			files: []string{"service.go", "service.page.go", "../../openrpc.json", "../../registry.go", "../../go.mod"},
+		},
+		{
+			name: "database-sql",
+			options: map[string]any{
+				"server_type":                   "jsonrpc",
+				"emit_methods_with_db_argument": true,
+			},
+			queries: queries,
+			files:   []string{"service.go", "../../registry.go", "../../main.go", "../../internal/dberrors/dberrors.go"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", tc.options, tc.queries...))
+			for _, name := range []string{"routes.go", "service.batch.go", "service.stream.go", "../../openapi.yml"} {
+				if _, ok := files[name]; ok {
+					t.Errorf("unexpected file %q", name)
+				}
+			}
+			assertGolden(t, filepath.Join("jsonrpc", tc.name), files, tc.files...)
+		})
+	}
+}
+
+func TestServerMcp(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Comments: []string{" GetAuthor returns the author of the id.", " http: GET /authors/{id}"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors WHERE name <> $1",
+			Filename: "query.sql",
+			Comments: []string{" ListAuthors lists the authors by id,", " except the author of the name.", " paginate: id"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+			},
+		},
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Comments: []string{" DeleteAuthor deletes the author of the id."},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		options map[string]any
+		files   []string
+	}{
+		{
+			name:    "pgx",
+			options: map[string]any{"server_type": "mcp", "sql_package": "pgx/v5", "skip_queries": "^Delete"},
+			files:   []string{"tools.json", "service.go", "../../registry.go", "../../main.go", "../../go.mod"},
+		},
+		{
+			name: "database-sql",
+			options: map[string]any{
+				"server_type":                   "mcp",
+				"emit_methods_with_db_argument": true,
+			},
+			files: []string{"tools.json", "service.go", "../../registry.go"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", tc.options, queries...))
+			for _, name := range []string{"routes.go", "../../openapi.yml", "../../openrpc.json"} {
+				if _, ok := files[name]; ok {
+					t.Errorf("unexpected file %q", name)
+				}
+			}
+			assertGolden(t, filepath.Join("mcp", tc.name), files, tc.files...)
+		})
+	}
+}
+
+func TestServerTwirp(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Comments: []string{" stream:"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		},
+		{
+			Name:     "ListAuthorNames",
+			Cmd:      ":many",
+			Text:     "SELECT name FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsName},
+		},
+		{
+			Name:     "PageAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors WHERE name <> $1",
+			Filename: "query.sql",
+			Comments: []string{" paginate: id"},
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+			},
+		},
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		options map[string]any
+		queries []*plugin.Query
+		files   []string
+	}{
+		{
+			name:    "pgx",
+			options: map[string]any{"server_type": "twirp", "sql_package": "pgx/v5"},
+			queries: append(queries, &plugin.Query{
+				Name:            "LoadAuthors",
+				Cmd:             ":copyfrom",
+				Text:            "INSERT INTO authors (name, bio) VALUES ($1, $2)",
+				Filename:        "query.sql",
+				InsertIntoTable: authorsTable,
+				Params: []*plugin.Parameter{
+					{Number: 1, Column: authorsName},
+					{Number: 2, Column: authorsBio},
+				},
+			}),
+			files: []string{
+				"service.go", "service.copyfrom.go", "service.page.go", "../../proto/authors/v1/authors.proto",
+				"../../registry.go", "../../buf.gen.yaml", "../../go.mod", "../../internal/dberrors/dberrors.go",
+			},
+		},
+		{
+			name: "database-sql",
+			options: map[string]any{
+				"server_type":                   "twirp",
+				"emit_methods_with_db_argument": true,
+				"tracing":                       true,
+			},
+			queries: queries,
+			files:   []string{"service.factory.go", "../../registry.go", "../../main.go", "../../internal/validation/validation.go"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", tc.options, tc.queries...))
+			for _, name := range []string{"service.stream.go", "../../internal/server/server.go", "../../internal/server/error_mapper.go"} {
+				if _, ok := files[name]; ok {
+					t.Errorf("unexpected file %q", name)
+				}
+			}
+			assertGolden(t, filepath.Join("twirp", tc.name), files, tc.files...)
+		})
+	}
+}
+
+func TestServerClient(t *testing.T) {
+	status := &plugin.Column{Name: "status", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	previousStatus := &plugin.Column{Name: "previous_status", Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	columns := []*plugin.Column{authorsID, authorsName, authorsBio, status, previousStatus}
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio, status, previous_status FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio, status) VALUES ($1, $2, $3) RETURNING id, name, bio, status, previous_status",
+			Filename: "query.sql",
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+				{Number: 3, Column: status},
+			},
+		},
+		{
+			Name:     "ListAuthorsByStatus",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status, previous_status FROM authors WHERE status = $1 AND bio = $2",
+			Filename: "query.sql",
+			Comments: []string{" Lists the authors of a status.", " http: GET /authors/{status}"},
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: status},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+		{
+			Name:     "ListAuthorNames",
+			Cmd:      ":many",
+			Text:     "SELECT name FROM authors ORDER BY name",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsName},
+		},
+		{
+			Name:     "GetAuthorPreviousStatus",
+			Cmd:      ":one",
+			Text:     "SELECT previous_status FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{previousStatus},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "UpdateAuthorBio",
+			Cmd:      ":execrows",
+			Text:     "UPDATE authors SET bio = $1 WHERE id = $2",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsBio},
+				{Number: 2, Column: authorsID},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ExportAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status, previous_status FROM authors ORDER BY id",
+			Filename: "query.sql",
+			Comments: []string{" stream: sse"},
+			Columns:  columns,
+		},
+		{
+			Name:     "PageAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status, previous_status FROM authors WHERE name <> $1",
+			Filename: "query.sql",
+			Comments: []string{" paginate: id"},
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+			},
+		},
+		{
+			Name:     "CreateAuthors",
+			Cmd:      ":batchone",
+			Text:     "INSERT INTO authors (name, status) VALUES ($1, $2) RETURNING id",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: status},
+			},
+		},
+		{
+			Name:            "LoadAuthors",
+			Cmd:             ":copyfrom",
+			Text:            "INSERT INTO authors (name, status) VALUES ($1, $2)",
+			Filename:        "query.sql",
+			InsertIntoTable: authorsTable,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: status},
+			},
+		},
+	}
+	newRequest := func(serverType string, typescript bool) *plugin.GenerateRequest {
+		req := authorsRequest(t, "postgresql", map[string]any{
+			"server_type":            serverType,
+			"sql_package":            "pgx/v5",
+			"emit_typescript_client": typescript,
+		}, queries...)
+		schema := req.Catalog.Schemas[0]
+		schema.Tables[0].Columns = columns
+		schema.Enums = []*plugin.Enum{{Name: "author_status", Vals: []string{"active", "on-leave", "retired"}}}
+		return req
+	}
+	files := generateServerFiles(t, newRequest("http", false))
+	assertGolden(t, "client", files, "../../client/authors/client.go", "../../go.mod")
+	if _, ok := files["../../client/authors/client.ts"]; ok {
+		t.Error("unexpected typescript client without emit_typescript_client")
+	}
+
+	files = generateServerFiles(t, newRequest("http", true))
+	assertGolden(t, "client", files, "../../client/authors/client.ts")
+
+	files = generateServerFiles(t, newRequest("jsonrpc", true))
+	for _, name := range []string{"../../client/authors/client.go", "../../client/authors/client.ts"} {
+		if _, ok := files[name]; ok {
+			t.Errorf("unexpected client of the jsonrpc server: %s", name)
+		}
+	}
+}
+
+func TestServerCli(t *testing.T) {
+	status := &plugin.Column{Name: "status", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "author_status"}}
+	columns := []*plugin.Column{authorsID, authorsName, authorsBio, status}
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio, status FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ListAuthorsByStatus",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status FROM authors WHERE status = $1",
+			Filename: "query.sql",
+			Comments: []string{" Lists the authors of a status."},
+			Columns:  columns,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: status},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "ExportAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status FROM authors ORDER BY id",
+			Filename: "query.sql",
+			Comments: []string{" stream:"},
+			Columns:  columns,
+		},
+		{
+			Name:     "PageAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio, status FROM authors",
+			Filename: "query.sql",
+			Comments: []string{" paginate: id"},
+			Columns:  columns,
+		},
+		{
+			Name:     "CreateAuthors",
+			Cmd:      ":batchone",
+			Text:     "INSERT INTO authors (name, status) VALUES ($1, $2) RETURNING id",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: status},
+			},
+		},
+		{
+			Name:            "LoadAuthors",
+			Cmd:             ":copyfrom",
+			Text:            "INSERT INTO authors (name, status) VALUES ($1, $2)",
+			Filename:        "query.sql",
+			InsertIntoTable: authorsTable,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: status},
+			},
+		},
+	}
+	newRequest := func(serverType string, options map[string]any) *plugin.GenerateRequest {
+		opts := map[string]any{
+			"server_type": serverType,
+			"sql_package": "pgx/v5",
+		}
+		for k, v := range options {
+			opts[k] = v
+		}
+		req := authorsRequest(t, "postgresql", opts, queries...)
+		schema := req.Catalog.Schemas[0]
+		schema.Tables[0].Columns = columns
+		schema.Enums = []*plugin.Enum{{Name: "author_status", Vals: []string{"active", "on-leave", "retired"}}}
+		return req
+	}
+	cli := map[string]any{"emit_cli": true}
+
+	files := generateServerFiles(t, newRequest("http", nil))
+	if _, ok := files["../../cmd/cli/authors.go"]; ok {
+		t.Error("unexpected cli without emit_cli")
+	}
+
+	files = generateServerFiles(t, newRequest("http", cli))
+	assertGolden(t, "cli/http", files, "../../cmd/cli/authors.go", "../../cmd/cli/dial.go", "../../cmd/cli/main.go")
+
+	files = generateServerFiles(t, newRequest("grpc", cli))
+	assertGolden(t, "cli/grpc", files, "../../cmd/cli/authors.go", "../../cmd/cli/dial.go", "../../go.mod")
+
+	files = generateServerFiles(t, newRequest("http", map[string]any{"emit_cli": true, "append": true}))
+	if _, ok := files["../../cmd/cli/authors.go"]; !ok {
+		t.Error("commands of the package not generated in append mode")
+	}
+	if _, ok := files["../../cmd/cli/main.go"]; ok {
+		t.Error("unexpected main of the cli in append mode")
+	}
+
+	files = generateServerFiles(t, newRequest("jsonrpc", cli))
+	if _, ok := files["../../cmd/cli/authors.go"]; ok {
+		t.Error("unexpected cli of the jsonrpc server")
+	}
+}
+
+func TestServerCustomTemplates(t *testing.T) {
+	query := &plugin.Query{
+		Name:     "GetAuthor",
+		Cmd:      ":one",
+		Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsID},
+		},
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "internal", "server"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "internal", "server", "version.go.tmpl"), []byte("package server\n\n// Module is {{.GoModule}}.\nconst Module = \"{{.GoModule}}\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the inline templates take precedence over the template_dir
+	if err := os.WriteFile(filepath.Join(dir, "routes.go"), []byte("package {{.Package}}\n\n// from the template_dir\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+		"sql_package":  "pgx/v5",
+		"template_dir": dir,
+		"templates": map[string]string{
+			"routes.go":    "package {{.Package}}\n\n{{range .Services}}// {{.Name}}\n{{end}}",
+			"README.md":    "# {{.GoModule}}\n",
+			"main.go.tmpl": "package main\n\nfunc main() {}\n",
+			"docs/api.md":  "# {{.GoModule}} API\n",
+		},
+	}, query))
+	for name, want := range map[string]string{
+		"routes.go":                        "package authors\n\n// GetAuthor\n",
+		"../../internal/server/version.go": "// Module is example.com/authors.",
+		"../../README.md":                  "# example.com/authors\n",
+		"../../main.go":                    "func main() {}",
+		"../../docs/api.md":                "# example.com/authors API\n",
+		"../../registry.go":                "package main",
+	} {
+		got, ok := files[name]
+		if !ok {
+			t.Errorf("file %q not generated", name)
+			continue
+		}
+		if !strings.Contains(got, want) {
+			t.Errorf("%s doesn't contain %q:\n%s", name, want, got)
+		}
+	}
+
+	req := authorsRequest(t, "postgresql", map[string]any{
+		"templates": map[string]string{"../main.go.tmpl": "package main\n"},
+	}, query)
+	if _, err := Generate(context.Background(), req); err == nil || !strings.Contains(err.Error(), "invalid template name") {
+		t.Errorf("expected an invalid template name error, got %v", err)
+	}
+}
+
+func TestServerProtectedRegions(t *testing.T) {
+	query := &plugin.Query{
+		Name:     "GetAuthor",
+		Cmd:      ":one",
+		Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsID},
+		},
+	}
+	newRequest := func(options map[string]any) *plugin.GenerateRequest {
+		opts := map[string]any{
+			"sql_package":       "pgx/v5",
+			"protected_regions": true,
+		}
+		for k, v := range options {
+			opts[k] = v
+		}
+		return authorsRequest(t, "postgresql", opts, query)
+	}
+	dir := t.TempDir()
+	files := generateServerFiles(t, newRequest(map[string]any{"snapshot_dir": dir}))
+	main := files["../../main.go"]
+	for _, region := range []string{"imports", "run", "custom"} {
+		if !strings.Contains(main, "// sqlc-gen-go-server:begin "+region+"\n") {
+			t.Errorf("main.go without the %s region:\n%s", region, main)
+		}
+	}
+	if !strings.Contains(files["../../registry.go"], "// sqlc-gen-go-server:begin custom\n") {
+		t.Error("registry.go without the custom region")
+	}
+	if strings.Contains(files["service.go"], "sqlc-gen-go-server:begin") {
+		t.Error("unexpected regions in the service.go, it isn't editable")
+	}
+
+	edited := strings.NewReplacer(
+		"// sqlc-gen-go-server:begin imports\n", "// sqlc-gen-go-server:begin imports\nimport \"strings\"\n",
+		"\t// sqlc-gen-go-server:begin run\n", "\t// sqlc-gen-go-server:begin run\n\tserver.Handler = lower(server.Handler)\n",
+		"// sqlc-gen-go-server:begin custom\n", "// sqlc-gen-go-server:begin custom\nfunc lower(h http.Handler) http.Handler {\n\treturn http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {\n\t\tr.URL.Path = strings.ToLower(r.URL.Path)\n\t\th.ServeHTTP(w, r)\n\t})\n}\n",
+	).Replace(main)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, options := range []map[string]any{
+		{"snapshot_dir": dir, "metric": true},
+		{"snapshot": map[string]string{"main.go": edited}, "metric": true},
+	} {
+		files = generateServerFiles(t, newRequest(options))
+		main = files["../../main.go"]
+		for _, want := range []string{"import \"strings\"", "server.Handler = lower(server.Handler)", "func lower(h http.Handler) http.Handler {", "prometheusPort"} {
+			if !strings.Contains(main, want) {
+				t.Errorf("main.go doesn't contain %q:\n%s", want, main)
+			}
+		}
+	}
+
+	removed := strings.Replace(edited, "// sqlc-gen-go-server:begin run", "// sqlc-gen-go-server:begin gone", 1)
+	removed = strings.Replace(removed, "// sqlc-gen-go-server:end run", "// sqlc-gen-go-server:end gone", 1)
+	_, err := Generate(context.Background(), newRequest(map[string]any{"snapshot": map[string]string{"main.go": removed}}))
+	if err == nil || !strings.Contains(err.Error(), `the region "gone" isn't generated anymore`) {
+		t.Errorf("expected an error of the lost region, got %v", err)
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Comments: []string{" auth: role=admin", " auth: role=owner"},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		auth       string
+		files      []string
+		// disabled is the file of the auth method not enabled
+		disabled string
+	}{
+		{
+			serverType: "http",
+			auth:       "jwt,api_key",
+			files: []string{"service.go", "routes.go", "../../registry.go", "../../internal/auth/auth.go",
+				"../../internal/auth/jwt.go", "../../internal/auth/apikey.go", "../../internal/auth/http.go"},
+		},
+		{
+			serverType: "grpc",
+			auth:       "jwt",
+			files:      []string{"service.go", "../../registry.go", "../../internal/server/config.go", "../../internal/auth/grpc.go"},
+			disabled:   "../../internal/auth/apikey.go",
+		},
+		{
+			serverType: "connect",
+			auth:       "api_key",
+			files:      []string{"service.go", "../../registry.go", "../../internal/auth/connect.go"},
+			disabled:   "../../internal/auth/jwt.go",
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+				"auth":        tc.auth,
+			}, queries...))
+			assertGolden(t, filepath.Join("auth", tc.serverType), files, tc.files...)
+			if _, ok := files[tc.disabled]; ok {
+				t.Errorf("file %q of a disabled auth method generated", tc.disabled)
+			}
+		})
+	}
+
+	t.Run("required", func(t *testing.T) {
+		public := &plugin.Query{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     queries[0].Text,
+			Filename: "query.sql",
+			Comments: []string{" auth: public"},
+			Columns:  queries[0].Columns,
+			Params:   queries[0].Params,
+		}
+		deleteAuthor := &plugin.Query{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     queries[1].Text,
+			Filename: "query.sql",
+			Params:   queries[1].Params,
+		}
+		files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+			"sql_package":   "pgx/v5",
+			"auth":          "jwt",
+			"auth_required": true,
+		}, public, deleteAuthor))
+		assertGolden(t, filepath.Join("auth", "required"), files, "service.go", "../../registry.go")
+	})
+
+	files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{"sql_package": "pgx/v5"}, queries[0]))
+	if strings.Contains(files["routes.go"], "auth.") {
+		t.Errorf("routes.go without the auth option uses the auth package:\n%s", files["routes.go"])
+	}
+	if _, ok := files["../../internal/auth/auth.go"]; ok {
+		t.Error("auth package generated without the auth option")
+	}
+
+	for _, tc := range []struct {
+		name     string
+		options  map[string]any
+		comments []string
+		err      string
+	}{
+		{
+			name:     "comment without option",
+			comments: []string{" auth: authenticated"},
+			err:      "require the auth option",
+		},
+		{
+			name:     "invalid requirement",
+			options:  map[string]any{"auth": "jwt"},
+			comments: []string{" auth: admin"},
+			err:      `invalid auth requirement "admin"`,
+		},
+		{
+			name:     "empty role",
+			options:  map[string]any{"auth": "jwt"},
+			comments: []string{" auth: role=admin,"},
+			err:      "empty role",
+		},
+		{
+			name:     "public with requirements",
+			options:  map[string]any{"auth": "jwt"},
+			comments: []string{" auth: public role=admin"},
+			err:      `the public auth comment can't require "role=admin"`,
+		},
+		{
+			name:     "public with other comments",
+			options:  map[string]any{"auth": "jwt"},
+			comments: []string{" auth: public", " auth: authenticated"},
+			err:      "can't be combined",
+		},
+		{
+			name:    "required without option",
+			options: map[string]any{"auth_required": true},
+			err:     "the auth_required option requires the auth option",
+		},
+		{
+			name:    "invalid method",
+			options: map[string]any{"auth": "basic"},
+			err:     `invalid auth method "basic"`,
+		},
+		{
+			name:    "unsupported server type",
+			options: map[string]any{"auth": "jwt", "server_type": "twirp"},
+			err:     "isn't supported by the twirp server type",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := map[string]any{"sql_package": "pgx/v5"}
+			for k, v := range tc.options {
+				opts[k] = v
+			}
+			query := &plugin.Query{
+				Name:     "DeleteAuthor",
+				Cmd:      ":exec",
+				Text:     "DELETE FROM authors WHERE id = $1",
+				Filename: "query.sql",
+				Comments: tc.comments,
+				Params: []*plugin.Parameter{
+					{Number: 1, Column: authorsID},
+				},
+			}
+			_, err := Generate(context.Background(), authorsRequest(t, "postgresql", opts, query))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestServerTenant(t *testing.T) {
+	authorsTenant := &plugin.Column{Name: "tenant_id", NotNull: true, Table: authorsTable, Type: &plugin.Identifier{Name: "bigint"}}
+	queries := []*plugin.Query{
+		{
+			Name:     "GetAuthor",
+			Cmd:      ":one",
+			Text:     "SELECT id, name, bio FROM authors WHERE id = $1 AND tenant_id = $2",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+				{Number: 2, Column: authorsTenant},
+			},
+		},
+		{
+			Name:     "ListAuthors",
+			Cmd:      ":many",
+			Text:     "SELECT id, name, bio FROM authors WHERE tenant_id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsTenant},
+			},
+		},
+		{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Comments: []string{" tenant: none"},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		options    map[string]any
+		files      []string
+	}{
+		{
+			serverType: "http",
+			options:    map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_param": "tenant_id"},
+			files: []string{"service.go", "routes.go", "../../registry.go", "../../internal/tenant/tenant.go",
+				"../../internal/tenant/http.go", "../../internal/auth/jwt.go"},
+		},
+		{
+			serverType: "grpc",
+			options:    map[string]any{"auth": "api_key", "tenant": "header:X-Tenant-ID", "tenant_setting": "app.tenant_id", "tenant_param": "tenant_id"},
+			files: []string{"service.go", "../../registry.go", "../../internal/server/config.go", "../../internal/server/server.go",
+				"../../internal/tenant/tenant.go", "../../internal/tenant/db.go", "../../internal/tenant/grpc.go"},
+		},
+		{
+			serverType: "connect",
+			options:    map[string]any{"auth": "jwt", "auth_required": true, "tenant": "claim:tenant_id", "tenant_setting": "app.tenant_id"},
+			files:      []string{"service.go", "../../registry.go", "../../internal/tenant/tenant.go", "../../internal/tenant/connect.go"},
+		},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			opts := map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}
+			for k, v := range tc.options {
+				opts[k] = v
+			}
+			qs := queries
+			if tc.options["tenant_param"] == nil {
+				// the tenant comment of DeleteAuthor requires the tenant_param option
+				qs = queries[:2]
+			}
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", opts, qs...))
+			assertGolden(t, filepath.Join("tenant", tc.serverType), files, tc.files...)
+			if _, ok := files["../../internal/tenant/db.go"]; ok != (tc.options["tenant_setting"] != nil) {
+				t.Errorf("db.go generated: %v, with the tenant_setting option: %v", ok, tc.options["tenant_setting"] != nil)
+			}
+		})
+	}
+
+	// the request of ListAuthors has only the tenant
+	files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+		"sql_package":  "pgx/v5",
+		"auth":         "jwt",
+		"tenant":       "header:X-Tenant-ID",
+		"tenant_param": "tenant_id",
+	}, queries[1]))
+	assertGolden(t, filepath.Join("tenant", "only"), files, "service.go")
+
+	deleteAuthor := func(comments ...string) *plugin.Query {
+		return &plugin.Query{
+			Name:     "DeleteAuthor",
+			Cmd:      ":exec",
+			Text:     "DELETE FROM authors WHERE id = $1",
+			Filename: "query.sql",
+			Comments: comments,
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsID},
+			},
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		options map[string]any
+		query   *plugin.Query
+		err     string
+	}{
+		{
+			name:    "setting without tenant",
+			options: map[string]any{"tenant_setting": "app.tenant_id"},
+			err:     "require the tenant option",
+		},
+		{
+			name:    "invalid tenant",
+			options: map[string]any{"tenant": "cookie:tenant", "tenant_param": "tenant_id"},
+			err:     `invalid tenant "cookie:tenant"`,
+		},
+		{
+			name:    "claim without jwt",
+			options: map[string]any{"tenant": "claim:tenant_id", "tenant_param": "tenant_id"},
+			err:     "requires the jwt auth method",
+		},
+		{
+			name:    "without setting and param",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID"},
+			err:     "requires the tenant_setting or the tenant_param option",
+		},
+		{
+			name:    "invalid setting",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_setting": "tenant_id"},
+			err:     `invalid tenant_setting "tenant_id"`,
+		},
+		{
+			name:    "setting with db argument",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_setting": "app.tenant_id", "emit_methods_with_db_argument": true},
+			err:     "can't be used with emit_methods_with_db_argument",
+		},
+		{
+			name:    "unsupported server type",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_param": "tenant_id", "server_type": "twirp"},
+			err:     "isn't supported by the twirp server type",
+		},
+		{
+			name:    "header without auth",
+			options: map[string]any{"tenant": "header:X-Tenant-ID", "tenant_param": "tenant_id"},
+			err:     "the tenant header requires the auth option",
+		},
+		{
+			name:    "query without tenant param",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_param": "tenant_id"},
+			query:   deleteAuthor(),
+			err:     "query DeleteAuthor: the query has no tenant_id param",
+		},
+		{
+			name:    "invalid tenant comment",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_param": "tenant_id"},
+			query:   deleteAuthor(" tenant: all"),
+			err:     `invalid tenant comment "tenant: all"`,
+		},
+		{
+			name:    "tenant comment without tenant param",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_setting": "app.tenant_id"},
+			query:   deleteAuthor(" tenant: none"),
+			err:     "the tenant comment requires the tenant_param option",
+		},
+		{
+			name:    "tenant comment with tenant param",
+			options: map[string]any{"auth": "jwt", "tenant": "header:X-Tenant-ID", "tenant_param": "tenant_id"},
+			query: &plugin.Query{
+				Name:     "ListAuthors",
+				Cmd:      ":many",
+				Text:     "SELECT id, name, bio FROM authors WHERE tenant_id = $1",
+				Filename: "query.sql",
+				Comments: []string{" tenant: none"},
+				Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+				Params: []*plugin.Parameter{
+					{Number: 1, Column: authorsTenant},
+				},
+			},
+			err: "the tenant comment can't annotate a query with the tenant_id param",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := map[string]any{"sql_package": "pgx/v5"}
+			for k, v := range tc.options {
+				opts[k] = v
+			}
+			query := tc.query
+			if query == nil {
+				query = queries[1]
+			}
+			_, err := Generate(context.Background(), authorsRequest(t, "postgresql", opts, query))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestServerTransaction(t *testing.T) {
+	booksTable := &plugin.Identifier{Name: "books"}
+	booksID := &plugin.Column{Name: "id", NotNull: true, Table: booksTable, Type: &plugin.Identifier{Name: "bigint"}}
+	booksAuthor := &plugin.Column{Name: "author_id", NotNull: true, Table: booksTable, Type: &plugin.Identifier{Name: "bigint"}}
+	booksTitle := &plugin.Column{Name: "title", NotNull: true, Table: booksTable, Type: &plugin.Identifier{Name: "text"}}
+	queries := []*plugin.Query{
+		{
+			Name:     "CreateAuthor",
+			Cmd:      ":one",
+			Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: authorsName},
+				{Number: 2, Column: authorsBio},
+			},
+		},
+		{
+			Name:     "CreateBook",
+			Cmd:      ":one",
+			Text:     "INSERT INTO books (author_id, title) VALUES ($1, $2) RETURNING id, author_id, title",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{booksID, booksAuthor, booksTitle},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: booksAuthor},
+				{Number: 2, Column: booksTitle},
+			},
+		},
+		{
+			Name:     "CountBooks",
+			Cmd:      ":one",
+			Text:     "SELECT count(*) FROM books WHERE author_id = $1",
+			Filename: "query.sql",
+			Columns:  []*plugin.Column{{Name: "count", NotNull: true, Type: &plugin.Identifier{Name: "bigint"}}},
+			Params: []*plugin.Parameter{
+				{Number: 1, Column: booksAuthor},
+			},
+		},
+	}
+	transaction := map[string]any{
+		"name": "PublishAuthor",
+		"steps": []any{
+			map[string]any{"query": "CreateAuthor"},
+			map[string]any{"query": "CreateBook", "repeated": true, "bind": map[string]any{"author_id": "CreateAuthor.id"}},
+			map[string]any{"query": "CountBooks", "bind": map[string]any{"author_id": "CreateAuthor.id"}},
+		},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{serverType: "http", files: []string{"service.tx.go", "routes.go", "../../openapi.yml"}},
+		{serverType: "grpc", files: []string{"service.tx.go", "../../proto/authors/v1/authors.proto"}},
+		{serverType: "connect", files: []string{"service.tx.go"}},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+				"server_type":  tc.serverType,
+				"sql_package":  "pgx/v5",
+				"transactions": []any{transaction},
+			}, queries...))
+			assertGolden(t, filepath.Join("transaction", tc.serverType), files, tc.files...)
+		})
+	}
+
+	// without transactions the file isn't generated
+	files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{"sql_package": "pgx/v5"}, queries...))
+	if _, ok := files["service.tx.go"]; ok {
+		t.Error("service.tx.go generated without transactions")
+	}
+
+	step := func(query string, bind map[string]any) map[string]any {
+		return map[string]any{"query": query, "bind": bind}
+	}
+	for _, tc := range []struct {
+		name    string
+		steps   []any
+		options map[string]any
+		err     string
+	}{
+		{
+			name:  "unknown query",
+			steps: []any{step("DeleteAuthor", nil)},
+			err:   "DeleteAuthor isn't a regular query of the package",
+		},
+		{
+			name:  "unknown param",
+			steps: []any{step("CreateAuthor", nil), step("CreateBook", map[string]any{"book_id": "CreateAuthor.id"})},
+			err:   `unknown param "book_id"`,
+		},
+		{
+			name:  "next step",
+			steps: []any{step("CreateBook", map[string]any{"author_id": "CreateAuthor.id"}), step("CreateAuthor", nil)},
+			err:   "which isn't a previous step",
+		},
+		{
+			name:  "unknown column",
+			steps: []any{step("CreateAuthor", nil), step("CreateBook", map[string]any{"author_id": "CreateAuthor.author_id"})},
+			err:   `unknown column "author_id"`,
+		},
+		{
+			name:  "type mismatch",
+			steps: []any{step("CreateAuthor", nil), step("CreateBook", map[string]any{"author_id": "CreateAuthor.name"})},
+			err:   "of type int64 is bound to CreateAuthor.name of type string",
+		},
+		{
+			name:    "unsupported server type",
+			steps:   []any{step("CreateAuthor", nil)},
+			options: map[string]any{"server_type": "graphql"},
+			err:     "aren't supported by the graphql server type",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := map[string]any{
+				"sql_package":  "pgx/v5",
+				"transactions": []any{map[string]any{"name": "PublishAuthor", "steps": tc.steps}},
+			}
+			for k, v := range tc.options {
+				opts[k] = v
+			}
+			_, err := Generate(context.Background(), authorsRequest(t, "postgresql", opts, queries...))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	createAuthor := &plugin.Query{
+		Name:     "CreateAuthor",
+		Cmd:      ":one",
+		Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+		Filename: "query.sql",
+		Comments: []string{" event: authors.created"},
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsName},
+			{Number: 2, Column: authorsBio},
+		},
+	}
+	deleteAuthor := &plugin.Query{
+		Name:     "DeleteAuthor",
+		Cmd:      ":exec",
+		Text:     "DELETE FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Comments: []string{" event: authors.deleted"},
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "http",
+			files:      []string{"query.sql.go", "outbox.go", "../../registry.go", "../../internal/outbox/relay.go", "../../internal/outbox/outbox.go"},
+		},
+		{serverType: "grpc", files: []string{"../../registry.go"}},
+		{serverType: "connect", files: []string{"../../registry.go"}},
+		{serverType: "mcp", files: []string{"../../registry.go"}},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}, createAuthor, deleteAuthor))
+			assertGolden(t, filepath.Join("events", tc.serverType), files, tc.files...)
+		})
+	}
+
+	for _, tc := range []struct {
+		lib   string
+		files []string
+	}{
+		{lib: "goose", files: []string{"../../sql/migrations/3_outbox.sql"}},
+		{lib: "migrate", files: []string{"../../sql/migrations/3_outbox.up.sql", "../../sql/migrations/3_outbox.down.sql"}},
+	} {
+		t.Run("migration "+tc.lib, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+				"sql_package":              "pgx/v5",
+				"migration_path":           "sql/migrations",
+				"migration_lib":            tc.lib,
+				"outbox_migration_version": "3",
+			}, deleteAuthor))
+			// the relay doesn't create the table of the migration
+			assertGolden(t, filepath.Join("events", tc.lib), files, append(tc.files, "../../internal/outbox/relay.go")...)
+		})
+	}
+
+	// without events the outbox isn't generated
+	files := generateServerFiles(t, authorsRequest(t, "sqlite", nil, &plugin.Query{
+		Name:     "DeleteAuthor",
+		Cmd:      ":exec",
+		Text:     "DELETE FROM authors WHERE id = ?",
+		Filename: "query.sql",
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}))
+	for _, name := range []string{"outbox.go", "../../internal/outbox/relay.go"} {
+		if _, ok := files[name]; ok {
+			t.Errorf("%s generated without events", name)
+		}
+	}
+	if strings.Contains(files["../../registry.go"], "outbox") {
+		t.Errorf("registry.go starts the relay without events:\n%s", files["../../registry.go"])
+	}
+
+	for _, tc := range []struct {
+		name    string
+		query   *plugin.Query
+		options map[string]any
+		err     string
+	}{
+		{
+			name: "select",
+			query: &plugin.Query{
+				Name:     "GetAuthor",
+				Cmd:      ":one",
+				Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+				Filename: "query.sql",
+				Comments: []string{" event: authors.read"},
+				Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+				Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+			},
+			err: "GetAuthor: -- event: requires an INSERT, UPDATE or DELETE statement",
+		},
+		{
+			name: "many",
+			query: &plugin.Query{
+				Name:     "ListAuthors",
+				Cmd:      ":many",
+				Text:     "SELECT id, name, bio FROM authors",
+				Filename: "query.sql",
+				Comments: []string{" event: authors.listed"},
+				Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+			},
+			err: "-- event: isn't supported by :many queries",
+		},
+		{
+			name: "invalid topic",
+			query: &plugin.Query{
+				Name:     "DeleteAuthor",
+				Cmd:      ":exec",
+				Text:     "DELETE FROM authors WHERE id = $1",
+				Filename: "query.sql",
+				Comments: []string{" event: authors deleted"},
+				Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+			},
+			err: `invalid -- event: topic "authors deleted"`,
+		},
+		{
+			name:    "db argument",
+			query:   deleteAuthor,
+			options: map[string]any{"emit_methods_with_db_argument": true},
+			err:     "-- event: can't be used with emit_methods_with_db_argument",
+		},
+		{
+			name:    "migration without version",
+			query:   deleteAuthor,
+			options: map[string]any{"migration_path": "sql/migrations"},
+			err:     "the outbox_migration_version option is required with the migration_path option",
+		},
+		{
+			name:    "invalid migration version",
+			query:   deleteAuthor,
+			options: map[string]any{"migration_path": "sql/migrations", "outbox_migration_version": "v1"},
+			err:     `invalid outbox_migration_version "v1"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := map[string]any{"sql_package": "pgx/v5"}
+			for k, v := range tc.options {
+				opts[k] = v
+			}
+			_, err := Generate(context.Background(), authorsRequest(t, "postgresql", opts, tc.query))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestServerWebhooks(t *testing.T) {
+	createAuthor := &plugin.Query{
+		Name:     "CreateAuthor",
+		Cmd:      ":one",
+		Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+		Filename: "query.sql",
+		Comments: []string{" webhook: authors.created, authors.changed"},
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsName},
+			{Number: 2, Column: authorsBio},
+		},
+	}
+	deleteAuthor := &plugin.Query{
+		Name:     "DeleteAuthor",
+		Cmd:      ":exec",
+		Text:     "DELETE FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Comments: []string{" webhook: authors.deleted"},
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}
+	for _, tc := range []struct {
+		serverType string
+		files      []string
+	}{
+		{
+			serverType: "http",
+			files:      []string{"service.go", "../../registry.go", "../../internal/webhook/webhook.go", "../../internal/webhook/subscriptions.go"},
+		},
+		{serverType: "grpc", files: []string{"service.go", "../../registry.go", "../../internal/webhook/proto.go"}},
+		{serverType: "connect", files: []string{"service.go", "../../registry.go"}},
+	} {
+		t.Run(tc.serverType, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+				"server_type": tc.serverType,
+				"sql_package": "pgx/v5",
+			}, createAuthor, deleteAuthor))
+			assertGolden(t, filepath.Join("webhooks", tc.serverType), files, tc.files...)
+			if _, ok := files["../../internal/webhook/proto.go"]; ok != (tc.serverType != "http") {
+				t.Errorf("proto.go generated: %v for the %s server", ok, tc.serverType)
+			}
+		})
+	}
+
+	t.Run("tenant transaction", func(t *testing.T) {
+		files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+			"sql_package":    "pgx/v5",
+			"auth":           "jwt",
+			"tenant":         "header:X-Tenant-ID",
+			"tenant_setting": "app.tenant_id",
+		}, createAuthor))
+		// the deliveries are queued after the commit of the request
+		assertGolden(t, filepath.Join("webhooks", "tenant"), files, "../../internal/webhook/webhook.go", "../../internal/tenant/db.go")
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+			"server_type": "grpc",
+			"sql_package": "pgx/v5",
+			"transactions": []any{map[string]any{
+				"name": "ReplaceAuthor",
+				"steps": []any{
+					map[string]any{"query": "DeleteAuthor", "repeated": true},
+					map[string]any{"query": "CreateAuthor"},
+				},
+			}},
+		}, createAuthor, deleteAuthor))
+		// the steps notify the webhooks after the commit
+		assertGolden(t, filepath.Join("webhooks", "transaction"), files, "service.tx.go")
+	})
+
+	for _, tc := range []struct {
+		lib   string
+		files []string
+	}{
+		{lib: "goose", files: []string{"../../sql/migrations/7_webhook_subscriptions.sql"}},
+		{lib: "migrate", files: []string{"../../sql/migrations/7_webhook_subscriptions.up.sql", "../../sql/migrations/7_webhook_subscriptions.down.sql"}},
+	} {
+		t.Run("migration "+tc.lib, func(t *testing.T) {
+			opts := map[string]any{"sql_package": "pgx/v5", "migration_path": "sql/migrations", "migration_lib": tc.lib, "webhook_migration_version": "7"}
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", opts, deleteAuthor))
+			// the subscriptions don't create the table of the migration
+			assertGolden(t, filepath.Join("webhooks", tc.lib), files, append(tc.files, "../../internal/webhook/subscriptions.go")...)
+		})
+	}
+
+	// without webhook comments the package isn't generated
+	files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{"sql_package": "pgx/v5", "migration_path": "sql/migrations"}, &plugin.Query{
+		Name:     "DeleteAuthor",
+		Cmd:      ":exec",
+		Text:     "DELETE FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}))
+	for name := range files {
+		if strings.Contains(name, "webhook") {
+			t.Errorf("%s generated without webhook comments", name)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		query   *plugin.Query
+		options map[string]any
+		err     string
+	}{
+		{
+			name: "invalid event",
+			query: &plugin.Query{
+				Name:     "DeleteAuthor",
+				Cmd:      ":exec",
+				Text:     "DELETE FROM authors WHERE id = $1",
+				Filename: "query.sql",
+				Comments: []string{" webhook: authors deleted"},
+				Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+			},
+			err: `query DeleteAuthor: invalid webhook event "authors deleted"`,
+		},
+		{
+			name: "batch",
+			query: &plugin.Query{
+				Name:     "DeleteAuthors",
+				Cmd:      ":batchexec",
+				Text:     "DELETE FROM authors WHERE id = $1",
+				Filename: "query.sql",
+				Comments: []string{" webhook: authors.deleted"},
+				Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+			},
+			err: "the webhook comment isn't supported by :batchexec queries",
+		},
+		{
+			name:    "server type",
+			query:   deleteAuthor,
+			options: map[string]any{"server_type": "graphql"},
+			err:     "the webhook comments aren't supported by the graphql server type",
+		},
+		{
+			name:    "migration without version",
+			query:   deleteAuthor,
+			options: map[string]any{"migration_path": "sql/migrations"},
+			err:     "the webhook_migration_version option is required with the migration_path option",
+		},
+		{
+			name:    "migration version",
+			query:   deleteAuthor,
+			options: map[string]any{"migration_path": "sql/migrations", "webhook_migration_version": "v1"},
+			err:     `invalid webhook_migration_version "v1"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := map[string]any{"sql_package": "pgx/v5"}
+			for k, v := range tc.options {
+				opts[k] = v
+			}
+			_, err := Generate(context.Background(), authorsRequest(t, "postgresql", opts, tc.query))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestServerCache(t *testing.T) {
+	getAuthor := &plugin.Query{
+		Name:     "GetAuthor",
+		Cmd:      ":one",
+		Text:     "SELECT id, name, bio FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Comments: []string{" cache: ttl=30s"},
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}
+	listAuthors := &plugin.Query{
+		Name:     "ListAuthors",
+		Cmd:      ":many",
+		Text:     "SELECT a.id, a.name, a.bio FROM public.authors a ORDER BY a.name",
+		Filename: "query.sql",
+		Comments: []string{" cache: ttl=5m"},
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+	}
+	createAuthor := &plugin.Query{
+		Name:     "CreateAuthor",
+		Cmd:      ":one",
+		Text:     "INSERT INTO authors (name, bio) VALUES ($1, $2) RETURNING id, name, bio",
+		Filename: "query.sql",
+		Comments: []string{" event: authors.created"},
+		Columns:  []*plugin.Column{authorsID, authorsName, authorsBio},
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsName},
+			{Number: 2, Column: authorsBio},
+		},
+	}
+	deleteAuthor := &plugin.Query{
+		Name:     "DeleteAuthor",
+		Cmd:      ":exec",
+		Text:     "DELETE FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}
+	removeAuthor := map[string]any{
+		"name":  "RemoveAuthor",
+		"steps": []any{map[string]any{"query": "DeleteAuthor"}},
+	}
+	for _, sqlPackage := range []string{"pgx/v5", "database/sql"} {
+		t.Run(sqlPackage, func(t *testing.T) {
+			files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{
+				"sql_package":  sqlPackage,
+				"transactions": []any{removeAuthor},
+			}, getAuthor, listAuthors, createAuthor, deleteAuthor))
+			assertGolden(t, filepath.Join("cache", sqlPackage), files, "query.sql.go", "cache.go", "db.go", "service.tx.go")
+		})
+	}
+
+	// the copies and the batches invalidate the cache too
+	loadAuthors := &plugin.Query{
+		Name:            "LoadAuthors",
+		Cmd:             ":copyfrom",
+		Text:            "INSERT INTO authors (name, bio) VALUES ($1, $2)",
+		Filename:        "query.sql",
+		InsertIntoTable: authorsTable,
+		Params: []*plugin.Parameter{
+			{Number: 1, Column: authorsName},
+			{Number: 2, Column: authorsBio},
+		},
+	}
+	deleteAuthors := &plugin.Query{
+		Name:     "DeleteAuthors",
+		Cmd:      ":batchexec",
+		Text:     "DELETE FROM authors WHERE id = $1",
+		Filename: "query.sql",
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}
+	files := generateServerFiles(t, authorsRequest(t, "postgresql", map[string]any{"sql_package": "pgx/v5"}, getAuthor, loadAuthors, deleteAuthors))
+	assertGolden(t, filepath.Join("cache", "copyfrom"), files, "copyfrom.go", "batch.go", "query.sql.go")
+
+	// without cached queries the changes don't invalidate the cache
+	files = generateServerFiles(t, authorsRequest(t, "postgresql", nil, deleteAuthor))
+	if _, ok := files["cache.go"]; ok {
+		t.Error("cache.go generated without cached queries")
+	}
+	if strings.Contains(files["query.sql.go"], "invalidate") {
+		t.Errorf("query.sql.go invalidates the cache without cached queries:\n%s", files["query.sql.go"])
+	}
+
+	for _, tc := range []struct {
+		name    string
+		comment string
+		query   *plugin.Query
+		options map[string]any
+		err     string
+	}{
+		{
+			name:    "ttl",
+			comment: " cache: ttl=soon",
+			query:   getAuthor,
+			err:     `GetAuthor: invalid -- cache: ttl "soon"`,
+		},
+		{
+			name:    "no ttl",
+			comment: " cache:",
+			query:   getAuthor,
+			err:     "-- cache: requires the ttl",
+		},
+		{
+			name:    "unknown option",
+			comment: " cache: ttl=30s size=10",
+			query:   getAuthor,
+			err:     `unknown -- cache: option "size=10"`,
+		},
+		{
+			name:    "exec",
+			comment: " cache: ttl=30s",
+			query:   deleteAuthor,
+			err:     "-- cache: isn't supported by :exec queries",
+		},
+		{
+			name:    "insert",
+			comment: " cache: ttl=30s",
+			query:   createAuthor,
+			err:     "-- cache: requires a SELECT statement",
+		},
+		{
+			name:    "db argument",
+			comment: " cache: ttl=30s",
+			query:   getAuthor,
+			options: map[string]any{"emit_methods_with_db_argument": true},
+			err:     "-- cache: can't be used with emit_methods_with_db_argument",
+		},
+		{
+			name:    "tenant setting",
+			comment: " cache: ttl=30s",
+			query:   getAuthor,
+			options: map[string]any{"auth": "jwt", "tenant": "claim:tenant_id", "tenant_setting": "app.tenant_id"},
+			err:     "-- cache: can't be used with the tenant_setting option",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			query := &plugin.Query{
+				Name:     tc.query.Name,
+				Cmd:      tc.query.Cmd,
+				Text:     tc.query.Text,
+				Filename: tc.query.Filename,
+				Comments: []string{tc.comment},
+				Columns:  tc.query.Columns,
+				Params:   tc.query.Params,
+			}
+			_, err := Generate(context.Background(), authorsRequest(t, "postgresql", tc.options, query))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// TestServerGeneratedPackages builds the generated auth and webhook packages
+// in a module of their own and runs the tests of testdata/server/packages
+// against them.
+func TestServerGeneratedPackages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command isn't installed")
+	}
+	files := generateServerFiles(t, authorsRequest(t, "sqlite", map[string]any{
+		"sql_package": "database/sql",
+		"auth":        "jwt,api_key",
+	}, &plugin.Query{
+		Name:     "DeleteAuthor",
+		Cmd:      ":exec",
+		Text:     "DELETE FROM authors WHERE id = ?",
+		Filename: "query.sql",
+		Comments: []string{" auth: role=admin", " webhook: authors.deleted"},
+		Params:   []*plugin.Parameter{{Number: 1, Column: authorsID}},
+	}))
+	dir := t.TempDir()
+	write := func(name string, contents []byte) {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, contents, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the packages import the standard library only, the module builds
+	// without downloads
+	write("go.mod", []byte("module example.com/authors\n\ngo 1.22\n"))
+	for name, contents := range files {
+		if name, ok := strings.CutPrefix(name, "../../"); ok && (strings.HasPrefix(name, "internal/auth/") || strings.HasPrefix(name, "internal/webhook/")) {
+			write(name, []byte(contents))
+		}
+	}
+	tests := filepath.Join("testdata", "server", "packages")
+	err = filepath.WalkDir(tests, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(tests, path)
+		if err != nil {
+			return err
+		}
+		write(filepath.ToSlash(name), contents)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goCmd, "test", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test of the generated packages: %v\n%s", err, out)
+	}
+}
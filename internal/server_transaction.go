@@ -0,0 +1,538 @@
+package golang
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sqlc-dev/sqlc-gen-go/internal/opts"
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// transactionService exposes the transaction of the options running the
+// queries of its steps, in order, in a single database transaction. The
+// request has the params of each step and the response has their results.
+type transactionService struct {
+	Name  string
+	Path  string
+	Steps []*transactionStep
+}
+
+// HasRequest reports if a step reads params from the request.
+func (t *transactionService) HasRequest() bool {
+	for _, s := range t.Steps {
+		if s.HasRequest() {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionStep calls a regular service of the package in the
+// transaction, once or once per item of the request if it's repeated.
+type transactionStep struct {
+	*metadata.Service
+	Repeated bool
+	// Binds fill params of the query with the results of the previous steps.
+	Binds []*transactionBind
+	// path is the http path of the transaction.
+	path string
+}
+
+// transactionBind fills a param of a step with the result of a previous step.
+type transactionBind struct {
+	// Field is the field of the params struct, empty for a single param.
+	Field string
+	// Source is the step with the result and Column the field of its result,
+	// empty for a single column.
+	Source *transactionStep
+	Column string
+}
+
+// transactionName matches the names of the transactions, used as the names of
+// the rpc and of the messages.
+var transactionName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// Var returns the prefix of the variables of the step in the handler.
+func (s *transactionStep) Var() string {
+	return converter.LowerFirstCharacter(s.Name)
+}
+
+// Field returns the Go field of the step in the request and in the response.
+func (s *transactionStep) Field() string {
+	return converter.CamelCaseProto(s.Name)
+}
+
+// JSONName returns the field of the step in the proto messages and in the
+// JSON bodies.
+func (s *transactionStep) JSONName() string {
+	return converter.ToSnakeCase(s.Name)
+}
+
+// HasRequest reports if the step reads params from the request. The params
+// filled with the tenant aren't read from it.
+func (s *transactionStep) HasRequest() bool {
+	return len(httpmetadata.RequestTypeAttributes(s.Service)) > 0
+}
+
+// HasResponse reports if the result of the step is in the response.
+func (s *transactionStep) HasResponse() bool {
+	return !s.EmptyOutput()
+}
+
+// toTransactionServices returns the transactions of the options, composing the
+// regular services of the package.
+func toTransactionServices(options *opts.Options, services []*metadata.Service) ([]*transactionService, error) {
+	if len(options.Transactions) == 0 {
+		return nil, nil
+	}
+	if options.EmitMethodsWithDbArgument {
+		return nil, fmt.Errorf("the transactions can't be used with emit_methods_with_db_argument")
+	}
+	switch options.ServerType {
+	case "", "http", "grpc", "connect", "twirp":
+	default:
+		return nil, fmt.Errorf("the transactions aren't supported by the %s server type. Choose 'http', 'grpc', 'connect' or 'twirp'", options.ServerType)
+	}
+	byName := make(map[string]*metadata.Service, len(services))
+	for _, s := range services {
+		byName[s.Name] = s
+	}
+	res := make([]*transactionService, 0, len(options.Transactions))
+	for _, t := range options.Transactions {
+		if !transactionName.MatchString(t.Name) {
+			return nil, fmt.Errorf("transaction %q: the name must be a PascalCase identifier", t.Name)
+		}
+		if _, ok := byName[t.Name]; ok {
+			return nil, fmt.Errorf("transaction %q: the name is the name of a query", t.Name)
+		}
+		svc := transactionService{
+			Name: t.Name,
+			Path: "/" + strings.Trim(t.Path, "/"),
+		}
+		if t.Path == "" {
+			svc.Path = "/transaction/" + converter.ToKebabCase(t.Name)
+		}
+		if strings.ContainsAny(svc.Path, "{} ") {
+			return nil, fmt.Errorf("transaction %s: the path %q can't have params", t.Name, t.Path)
+		}
+		steps := make(map[string]*transactionStep, len(t.Steps))
+		for _, st := range t.Steps {
+			s, ok := byName[st.Query]
+			if !ok {
+				// the batch, copyfrom, stream and page queries have their own endpoints
+				return nil, fmt.Errorf("transaction %s: %s isn't a regular query of the package", t.Name, st.Query)
+			}
+			if _, ok := steps[s.Name]; ok {
+				return nil, fmt.Errorf("transaction %s: the query %s is called more than once, use a repeated step", t.Name, s.Name)
+			}
+			step := &transactionStep{Service: s, Repeated: st.Repeated, path: svc.Path}
+			if step.Repeated && !step.HasRequest() {
+				return nil, fmt.Errorf("transaction %s: the repeated step %s has no params", t.Name, s.Name)
+			}
+			params := make([]string, 0, len(st.Bind))
+			for param := range st.Bind {
+				params = append(params, param)
+			}
+			sort.Strings(params)
+			for _, param := range params {
+				b, err := toTransactionBind(step, param, st.Bind[param], steps)
+				if err != nil {
+					return nil, fmt.Errorf("transaction %s: step %s: %w", t.Name, s.Name, err)
+				}
+				step.Binds = append(step.Binds, b)
+			}
+			steps[s.Name] = step
+			svc.Steps = append(svc.Steps, step)
+		}
+		res = append(res, &svc)
+	}
+	return res, nil
+}
+
+// toTransactionBind returns the binding of the param, by the name of its
+// request field, to the source like "CreateOrder.id" or "CreateOrder".
+func toTransactionBind(step *transactionStep, param, source string, previous map[string]*transactionStep) (*transactionBind, error) {
+	var (
+		b     transactionBind
+		typ   string
+		found bool
+	)
+	if step.HasCustomParams() {
+		for _, f := range step.Messages[converter.CanonicalName(step.InputTypes[0])].Fields {
+			if converter.ToSnakeCase(f.Name) == param {
+				b.Field, typ, found = converter.UpperFirstCharacter(f.Name), f.Type, true
+				break
+			}
+		}
+	} else if !step.EmptyInput() && converter.ToSnakeCase(step.InputNames[0]) == param {
+		typ, found = step.InputTypes[0], true
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown param %q", param)
+	}
+	name, column, _ := strings.Cut(source, ".")
+	src, ok := previous[name]
+	if !ok {
+		return nil, fmt.Errorf("the param %s is bound to %q, which isn't a previous step", param, source)
+	}
+	if src.Repeated || src.HasArrayOutput() || src.EmptyOutput() {
+		return nil, fmt.Errorf("the param %s is bound to %s, which doesn't return a single row", param, name)
+	}
+	b.Source = src
+	srcType := src.Output
+	if m, ok := src.Messages[converter.CanonicalName(src.Output)]; ok && src.HasCustomOutput() {
+		srcType = ""
+		for _, f := range m.Fields {
+			if converter.ToSnakeCase(f.Name) == column {
+				b.Column, srcType = converter.UpperFirstCharacter(f.Name), f.Type
+				break
+			}
+		}
+		if srcType == "" {
+			return nil, fmt.Errorf("the param %s is bound to the unknown column %q of %s", param, column, name)
+		}
+	} else if column != "" {
+		return nil, fmt.Errorf("the param %s is bound to the column %q of %s, which returns a single column", param, column, name)
+	}
+	if srcType != typ {
+		return nil, fmt.Errorf("the param %s of type %s is bound to %s of type %s", param, typ, source, srcType)
+	}
+	return &b, nil
+}
+
+// bind returns the lines filling the params in the arg variable.
+func (s *transactionStep) bind(arg string) []string {
+	res := make([]string, 0, len(s.Binds))
+	for _, b := range s.Binds {
+		dst, src := arg, b.Source.Var()+"Result"
+		if b.Field != "" {
+			dst += "." + b.Field
+		}
+		if b.Column != "" {
+			src += "." + b.Column
+		}
+		res = append(res, fmt.Sprintf("%s = %s", dst, src))
+	}
+	return res
+}
+
+// transactionCall returns the lines of a step in the transaction function:
+// the params are bound to the previous results and validated, the query is
+// called with the q queries and the result is added to the response.
+func transactionCall(s *transactionStep, ctx string, validators serverValidators, onInvalid string, result func(s *transactionStep, src string, assign func(string) []string) []string) []string {
+	call := func(arg string) string {
+		if s.EmptyInput() {
+			return fmt.Sprintf("q.%s(%s)", s.Name, ctx)
+		}
+		return fmt.Sprintf("q.%s(%s, %s)", s.Name, ctx, arg)
+	}
+	validate := func(arg string) []string {
+		v, ok := validators.lookup(s.Service)
+		if !ok || s.EmptyInput() {
+			return nil
+		}
+		expr := fmt.Sprintf("%s(%s)", v.funcName(), arg)
+		if v.Params != "" {
+			expr = arg + ".Validate()"
+		}
+		return []string{fmt.Sprintf("if err := %s; err != nil {", expr), onInvalid, "}"}
+	}
+	res := make([]string, 0)
+	if s.Repeated {
+		res = append(res, fmt.Sprintf("for _, arg := range %sArgs {", s.Var()))
+		res = append(res, s.bind("arg")...)
+		res = append(res, validate("arg")...)
+		if s.EmptyOutput() {
+			res = append(res, fmt.Sprintf("if err := %s; err != nil {", call("arg")), "return err", "}")
+		} else {
+			res = append(res, fmt.Sprintf("result, err := %s", call("arg")), "if err != nil {", "return err", "}")
+			res = append(res, result(s, "result", func(expr string) []string {
+				return []string{fmt.Sprintf("res.%s = append(res.%s, %s)", s.Field(), s.Field(), expr)}
+			})...)
+		}
+		return append(res, "}")
+	}
+	arg := s.Var() + "Arg"
+	res = append(res, s.bind(arg)...)
+	res = append(res, validate(arg)...)
+	if s.EmptyOutput() {
+		return append(res, fmt.Sprintf("if err := %s; err != nil {", call(arg)), "return err", "}")
+	}
+	src := s.Var() + "Result"
+	res = append(res, fmt.Sprintf("%s, err := %s", src, call(arg)), "if err != nil {", "return err", "}")
+	return append(res, result(s, src, func(expr string) []string {
+		return []string{fmt.Sprintf("res.%s = %s", s.Field(), expr)}
+	})...)
+}
+
+// transactionInputGrpc converts a req item of a step to the query params. The
+// params are validated in the transaction, after the binding. It's shared by
+// the grpc and connect servers.
+func transactionInputGrpc(s *transactionStep, enums serverEnums) []string {
+	return enums.inputGrpc(s.Service, "req")
+}
+
+// transactionResultGrpc assigns the <Name>Response of the result of a step. It's
+// shared by the grpc and connect servers.
+func transactionResultGrpc(s *transactionStep, enums serverEnums, src string, assign func(string) []string) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	if f, ok := execCountField(s.Service); ok {
+		return assign(fmt.Sprintf("&pb.%sResponse{%s: %s}", name, f.Name, src))
+	}
+	elem := converter.CanonicalName(s.Output)
+	adapter, convert := enums.adapter(elem)
+	if _, custom := s.Messages[elem]; custom {
+		adapter, convert = "to"+elem, true
+	}
+	switch {
+	case s.Output == "sql.Result" || s.Output == "pgconn.CommandTag":
+		return assign(fmt.Sprintf("&pb.%sResponse{Value: toExecResult(%s)}", name, src))
+	case s.HasArrayOutput() && convert:
+		res := []string{
+			"{",
+			fmt.Sprintf("item := new(pb.%sResponse)", name),
+			fmt.Sprintf("for _, r := range %s {", src),
+			fmt.Sprintf("item.List = append(item.List, %s(r))", adapter),
+			"}",
+		}
+		res = append(res, assign("item")...)
+		return append(res, "}")
+	case s.HasArrayOutput():
+		return assign(fmt.Sprintf("&pb.%sResponse{List: %s}", name, src))
+	case s.HasCustomOutput():
+		return assign(fmt.Sprintf("&pb.%sResponse{%s: %s(%s)}", name, converter.CamelCaseProto(elem), adapter, src))
+	case convert:
+		return assign(fmt.Sprintf("&pb.%sResponse{Value: %s(%s)}", name, adapter, src))
+	}
+	return assign(fmt.Sprintf("&pb.%sResponse{Value: %s}", name, src))
+}
+
+// transactionInputHttp converts a req item of a step, decoded by the handler,
+// to the query params.
+func transactionInputHttp(s *transactionStep) []string {
+	if s.EmptyInput() {
+		return nil
+	}
+	return itemInputHttp(s.Service, s.path)
+}
+
+// responseType returns the type of the result of a step in the http response,
+// and the type declared for it, if any.
+func (s *transactionStep) responseType() (string, bool) {
+	if _, ok := execCountField(s.Service); ok || len(httpmetadata.ResponseTypeAttributes(s.Service)) > 0 {
+		typ := s.Var() + "Response"
+		if s.HasArrayOutput() {
+			return "[]" + typ, true
+		}
+		return typ, true
+	}
+	return "any", false
+}
+
+// transactionHandlerTypes declares the request and the response of a
+// transaction, with a field per step.
+func transactionHandlerTypes(t *transactionService) []string {
+	res := make([]string, 0)
+	for _, s := range t.Steps {
+		if s.HasRequest() {
+			res = append(res, fmt.Sprintf("type %sRequest struct {", s.Var()))
+			res = append(res, httpmetadata.RequestTypeAttributes(s.Service)...)
+			res = append(res, "}")
+		}
+		if _, declared := s.responseType(); declared {
+			res = append(res, fmt.Sprintf("type %sResponse struct {", s.Var()))
+			if f, ok := execCountField(s.Service); ok {
+				res = append(res, fmt.Sprintf("%s int64 `json:\"%s\"`", f.Name, converter.ToSnakeCase(f.Name)))
+			} else {
+				res = append(res, httpmetadata.ResponseTypeAttributes(s.Service)...)
+			}
+			res = append(res, "}")
+		}
+	}
+	res = append(res, "type request struct {")
+	for _, s := range t.Steps {
+		if !s.HasRequest() {
+			continue
+		}
+		typ := s.Var() + "Request"
+		if s.Repeated {
+			typ = "[]" + typ
+		}
+		res = append(res, fmt.Sprintf("%s %s `json:\"%s\"`", s.Field(), typ, s.JSONName()))
+	}
+	res = append(res, "}")
+	res = append(res, "type response struct {")
+	for _, s := range t.Steps {
+		if !s.HasResponse() {
+			continue
+		}
+		typ, declared := s.responseType()
+		switch {
+		case s.Repeated:
+			typ = "[]" + typ
+		case declared && !s.HasArrayOutput():
+			typ = "*" + typ
+		}
+		res = append(res, fmt.Sprintf("%s %s `json:\"%s,omitempty\"`", s.Field(), typ, s.JSONName()))
+	}
+	res = append(res, "}")
+	return res
+}
+
+// transactionResultHttp assigns the result of a step, encoded like the
+// response of its own endpoint.
+func transactionResultHttp(s *transactionStep, src string, assign func(string) []string) []string {
+	typ, declared := s.responseType()
+	item := "item"
+	if declared && !s.Repeated && !s.HasArrayOutput() {
+		item = "&item"
+	}
+	res := []string{"{"}
+	switch m := s.Messages[converter.CanonicalName(s.Output)]; {
+	case !declared && s.HasArrayOutput():
+		return assign(fmt.Sprintf("map[string]any{\"list\": %s}", src))
+	case !declared:
+		return assign(fmt.Sprintf("map[string]any{\"value\": %s}", src))
+	case s.Output == "sql.Result":
+		res = append(res, fmt.Sprintf("lastInsertId, _ := %s.LastInsertId()", src))
+		res = append(res, fmt.Sprintf("rowsAffected, _ := %s.RowsAffected()", src))
+		res = append(res, fmt.Sprintf("item := %s{LastInsertId: lastInsertId, RowsAffected: rowsAffected}", typ))
+	case s.Output == "pgconn.CommandTag":
+		res = append(res, fmt.Sprintf("item := %s{RowsAffected: %s.RowsAffected()}", typ, src))
+	case m == nil:
+		f, _ := execCountField(s.Service)
+		res = append(res, fmt.Sprintf("item := %s{%s: %s}", typ, f.Name, src))
+	case s.HasArrayOutput():
+		elem := strings.TrimPrefix(typ, "[]")
+		res = append(res, fmt.Sprintf("item := make(%s, 0, len(%s))", typ, src))
+		res = append(res, fmt.Sprintf("for _, r := range %s {", src))
+		res = append(res, fmt.Sprintf("var v %s", elem))
+		for _, f := range m.Fields {
+			res = append(res, httpmetadata.BindToSerializable("r", "v", converter.UpperFirstCharacter(f.Name), f.Type)...)
+		}
+		res = append(res, "item = append(item, v)")
+		res = append(res, "}")
+	default:
+		res = append(res, fmt.Sprintf("var item %s", typ))
+		for _, f := range m.Fields {
+			res = append(res, httpmetadata.BindToSerializable(src, "item", converter.UpperFirstCharacter(f.Name), f.Type)...)
+		}
+	}
+	res = append(res, assign(item)...)
+	return append(res, "}")
+}
+
+func transactionProtoRPCs(t *transactionService, serverType string) []string {
+	rpc := fmt.Sprintf("rpc %s(%sRequest) returns (%sResponse)", t.Name, t.Name, t.Name)
+	if serverType != "grpc" {
+		return []string{rpc + " { }"}
+	}
+	return []string{
+		rpc + " {",
+		"    option (google.api.http) = {",
+		fmt.Sprintf("        post: \"%s\"", t.Path),
+		"        body: \"*\"",
+		"    };",
+		"}",
+	}
+}
+
+func transactionProtoMessages(t *transactionService) []string {
+	res := []string{"", fmt.Sprintf("message %sRequest {", t.Name)}
+	var n int
+	for _, s := range t.Steps {
+		if !s.HasRequest() {
+			continue
+		}
+		n++
+		res = append(res, fmt.Sprintf("    %s%sRequest %s = %d;", repeated(s.Repeated), converter.UpperFirstCharacter(s.Name), s.JSONName(), n))
+	}
+	res = append(res, "}", "", fmt.Sprintf("message %sResponse {", t.Name))
+	n = 0
+	for _, s := range t.Steps {
+		if !s.HasResponse() {
+			continue
+		}
+		n++
+		res = append(res, fmt.Sprintf("    %s%sResponse %s = %d;", repeated(s.Repeated), converter.UpperFirstCharacter(s.Name), s.JSONName(), n))
+	}
+	return append(res, "}")
+}
+
+func repeated(ok bool) string {
+	if ok {
+		return "repeated "
+	}
+	return ""
+}
+
+// transactionApiPaths returns the OpenAPI paths of the transaction endpoints.
+func transactionApiPaths(pkg *serverPackage) []string {
+	res := make([]string, 0)
+	for _, t := range pkg.TransactionServices {
+		res = append(res, fmt.Sprintf("%s:", t.Path))
+		res = append(res, "  post:")
+		res = append(res, "    tags:")
+		res = append(res, fmt.Sprintf("      - %s", pkg.Package))
+		res = append(res, fmt.Sprintf("    summary: %s", t.Name))
+		res = append(res, "    description: Runs the queries in a single transaction, rolled back on any error.")
+		res = append(res, "    requestBody:")
+		res = append(res, "      content:")
+		res = append(res, "        application/json:")
+		res = append(res, "          schema:")
+		res = append(res, "            type: object")
+		res = append(res, "            properties:")
+		for _, s := range t.Steps {
+			if !s.HasRequest() {
+				continue
+			}
+			svc := *s.Service
+			svc.HttpSpecs = []metadata.HttpSpec{{Method: "POST", Path: t.Path}}
+			schema := pkg.Validators.apiSchema(&svc, pkg.Enums.apiSchema(httpmetadata.ApiParameters(&svc), pkg.Enums.serviceTypes(&svc)))
+			res = append(res, fmt.Sprintf("              %s:", s.JSONName()))
+			if s.Repeated {
+				res = append(res, "                type: array")
+				res = append(res, "                items:")
+				res = append(res, indentSchema(schema, "      schema:", 18)...)
+				continue
+			}
+			res = append(res, indentSchema(schema, "      schema:", 16)...)
+		}
+		res = append(res, "    responses:")
+		res = append(res, "      \"200\":")
+		res = append(res, "        description: OK")
+		res = append(res, "        content:")
+		res = append(res, "          application/json:")
+		res = append(res, "            schema:")
+		res = append(res, "              type: object")
+		res = append(res, "              properties:")
+		for _, s := range t.Steps {
+			if !s.HasResponse() {
+				continue
+			}
+			indent := 18
+			res = append(res, fmt.Sprintf("                %s:", s.JSONName()))
+			if s.Repeated {
+				res = append(res, "                  type: array")
+				res = append(res, "                  items:")
+				indent = 20
+			}
+			schema := indentSchema(pkg.Enums.apiResponse(s.Service, httpmetadata.ApiResponse(s.Service)), "    schema:", indent)
+			if len(schema) == 0 {
+				schema = []string{strings.Repeat(" ", indent) + "type: object"}
+			}
+			res = append(res, schema...)
+		}
+		res = append(res, "      \"default\":")
+		res = append(res, "        description: Error message")
+		res = append(res, "        content:")
+		res = append(res, "          text/plain:")
+		res = append(res, "            schema:")
+		res = append(res, "              type: string")
+	}
+	return res
+}
@@ -0,0 +1,28 @@
+package golang
+
+import "io/fs"
+
+// twirpBaseTemplates returns the templates shared by the twirp server: the
+// proto files and the instrumentation of sqlc-connect, which don't depend on
+// the gRPC runtime, and the services and adapters of the grpc server, whose
+// methods have the signatures of the twirp interfaces. The gRPC server and
+// the stream methods aren't generated, twirp only supports unary methods.
+func twirpBaseTemplates(grpcFS, connectFS fs.FS) fs.FS {
+	return overlayFS{
+		top: excludeFS{
+			base: connectFS,
+			names: []string{
+				"main.go.tmpl", "registry.go.tmpl", "service.go.tmpl", "service.factory.go.tmpl",
+				"adapters.go.tmpl", "buf.gen.yaml", "tools/tools.go.tmpl", "internal/validation/validation.go.tmpl",
+			},
+		},
+		base: excludeFS{
+			base: grpcFS,
+			names: []string{
+				"service.stream.go.tmpl",
+				"internal/server/config.go.tmpl", "internal/server/error_mapper.go.tmpl", "internal/server/server.go.tmpl",
+				"internal/server/middleware/cors.go.tmpl",
+			},
+		},
+	}
+}
@@ -0,0 +1,302 @@
+package golang
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+	httpmetadata "github.com/walterwanderley/sqlc-http/metadata"
+)
+
+// typescriptFuncs adds the funcs of the TypeScript client of the http server.
+// The interfaces are translated from the types of the Go client, so both
+// clients send and receive the same JSON.
+func typescriptFuncs(funcs template.FuncMap, enums serverEnums) template.FuncMap {
+	res := maps.Clone(funcs)
+	handlerTypes := funcs["HandlerTypes"].(func(*metadata.Service) []string)
+	clientTypes := funcs["ClientTypes"].(func(*metadata.Service) []string)
+	batchTypes := funcs["ClientBatchTypes"].(func(*batchService) []string)
+	copyFromTypes := funcs["ClientCopyFromTypes"].(func(*copyFromService) []string)
+	streamTypes := funcs["ClientStreamTypes"].(func(*streamService) []string)
+	pageTypes := funcs["ClientPageTypes"].(func(*pageService) []string)
+	res["TsEnums"] = enums.tsEnums
+	res["TsTypes"] = func(s *metadata.Service) []string {
+		return enums.tsTypes(clientTypes(s))
+	}
+	res["TsMethod"] = func(s *metadata.Service) []string {
+		return tsMethod(s, handlerTypes(s), enums)
+	}
+	res["TsBatchTypes"] = func(s *batchService) []string {
+		return enums.tsTypes(batchTypes(s))
+	}
+	res["TsBatchMethod"] = tsBatchMethod
+	res["TsCopyFromTypes"] = func(s *copyFromService) []string {
+		return enums.tsTypes(copyFromTypes(s))
+	}
+	res["TsCopyFromMethod"] = tsCopyFromMethod
+	res["TsStreamTypes"] = func(s *streamService) []string {
+		return enums.tsTypes(streamTypes(s))
+	}
+	res["TsStreamMethod"] = func(s *streamService) []string {
+		return tsStreamMethod(s, enums)
+	}
+	res["TsPageTypes"] = func(s *pageService) []string {
+		// the first page is requested without a page_token
+		return enums.tsTypes(pageTypes(s), "page_size", "page_token")
+	}
+	res["TsPageMethod"] = tsPageMethod
+	return res
+}
+
+// tsEnums declares the enums of the package as unions of their values.
+func (enums serverEnums) tsEnums() []string {
+	res := make([]string, 0, len(enums))
+	for _, e := range enums {
+		values := make([]string, 0, len(e.Values))
+		for _, v := range e.Values {
+			values = append(values, fmt.Sprintf("%q", v.Value))
+		}
+		res = append(res, fmt.Sprintf("export type %s = %s;", e.Name, strings.Join(values, " | ")))
+	}
+	return res
+}
+
+// tsType returns the TypeScript type of the Go type of a field of the
+// client. The numbers are encoded as JSON numbers, so the 64-bit integers
+// beyond 2^53 lose precision, and the times and byte slices are strings.
+func (enums serverEnums) tsType(typ string, local []string) string {
+	switch {
+	case typ == "[]byte":
+		return "string"
+	case strings.HasPrefix(typ, "[]"):
+		elem := enums.tsType(typ[2:], local)
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case strings.HasPrefix(typ, "*"):
+		return enums.tsType(typ[1:], local) + " | null"
+	}
+	switch typ {
+	case "string", "time.Time", "uuid.UUID", "net.IP", "net.HardwareAddr", "netip.Addr", "netip.Prefix":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "byte",
+		"float32", "float64":
+		return "number"
+	}
+	if e, null, ok := enums.lookup(typ); ok && !null {
+		return e.Name
+	}
+	if slices.Contains(local, typ) {
+		return typ
+	}
+	return "unknown"
+}
+
+var tsField = regexp.MustCompile("^(\\w+)\\s+(\\S+)\\s*(?:`(.*)`)?$")
+
+// tsTypes translates the struct types of the Go client to interfaces. The
+// fields omitted when empty, the pointers and the optional fields are
+// optional, and the pointers sent as null are nullable.
+func (enums serverEnums) tsTypes(lines []string, optional ...string) []string {
+	local := make([]string, 0)
+	for _, line := range lines {
+		if name, ok := strings.CutPrefix(line, "type "); ok {
+			local = append(local, strings.Fields(name)[0])
+		}
+	}
+	res := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line == "}":
+			res = append(res, line)
+			continue
+		case strings.HasPrefix(line, "type "):
+			res = append(res, fmt.Sprintf("export interface %s {", strings.Fields(line)[1]))
+			continue
+		}
+		m := tsField.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name, typ := m[1], m[2]
+		var omitEmpty bool
+		if tag, ok := tsJSONTag(m[3]); ok {
+			v, opts, _ := strings.Cut(tag, ",")
+			if v == "-" {
+				continue
+			}
+			if v != "" {
+				name = v
+			}
+			omitEmpty = slices.Contains(strings.Split(opts, ","), "omitempty")
+		}
+		pointer := strings.HasPrefix(typ, "*")
+		tsTyp := enums.tsType(strings.TrimPrefix(typ, "*"), local)
+		if pointer && !omitEmpty {
+			tsTyp += " | null"
+		}
+		var opt string
+		if omitEmpty || pointer || slices.Contains(optional, name) {
+			opt = "?"
+		}
+		res = append(res, fmt.Sprintf("  %s%s: %s;", name, opt, tsTyp))
+	}
+	return res
+}
+
+func tsJSONTag(tag string) (string, bool) {
+	for _, kv := range strings.Fields(tag) {
+		if v, ok := strings.CutPrefix(kv, "json:"); ok {
+			return strings.Trim(v, `"`), true
+		}
+	}
+	return "", false
+}
+
+// tsRequest returns the path, query and body arguments of the request. The
+// path parameters are replaced by the fields of the request, the GET and
+// DELETE requests send the other fields as query parameters and the other
+// requests send the request as the body.
+func tsRequest(method, path string, fields []clientField) string {
+	params := make(map[string]struct{})
+	var b strings.Builder
+	last := 0
+	for _, m := range clientPathParam.FindAllStringSubmatchIndex(path, -1) {
+		b.WriteString(tsTemplateText(path[last:m[0]]))
+		last = m[1]
+		name := strings.TrimSuffix(path[m[2]:m[3]], "...")
+		if name == "$" || name == "" {
+			continue
+		}
+		params[name] = struct{}{}
+		fmt.Fprintf(&b, "${encodeURIComponent(String(req.%s))}", name)
+	}
+	b.WriteString(tsTemplateText(path[last:]))
+	pathArg := fmt.Sprintf("%q", path)
+	if len(params) > 0 {
+		pathArg = "`" + b.String() + "`"
+	}
+	if method != "GET" && method != "DELETE" {
+		if len(fields) == 0 {
+			return pathArg + ", undefined, undefined"
+		}
+		return pathArg + ", undefined, req"
+	}
+	query := make([]string, 0)
+	for _, f := range fields {
+		if _, ok := params[f.Param]; !ok {
+			query = append(query, fmt.Sprintf("%s: req.%s", f.Param, f.Param))
+		}
+	}
+	if len(query) == 0 {
+		return pathArg + ", undefined, undefined"
+	}
+	return fmt.Sprintf("%s, { %s }, undefined", pathArg, strings.Join(query, ", "))
+}
+
+func tsTemplateText(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`", "$", "\\$").Replace(s)
+}
+
+// tsMethod returns the method of the Client calling the endpoint of the
+// service. The methods are named after the queries, like getAuthor.
+func tsMethod(s *metadata.Service, handlerTypes []string, enums serverEnums) []string {
+	method := httpmetadata.HttpMethod(s)
+	name := converter.UpperFirstCharacter(s.Name)
+	res := make([]string, 0)
+	args := tsRequest(method, httpmetadata.HttpPath(s), clientFields(s))
+	call := fmt.Sprintf("(%q, %s, signal)", method, args)
+
+	var response bool
+	for _, line := range handlerTypes {
+		response = response || line == "type response struct {"
+	}
+	var ret string
+	switch {
+	case s.EmptyOutput():
+		ret = "void"
+		res = append(res, "await this.sendJSON"+call+";")
+	case response && s.HasArrayOutput():
+		ret = name + "Response[]"
+		res = append(res, fmt.Sprintf("return this.callJSON<%s>%s;", ret, call))
+	case response:
+		ret = name + "Response"
+		res = append(res, fmt.Sprintf("return this.callJSON<%s>%s;", ret, call))
+	case s.HasArrayOutput():
+		ret = enums.tsType("[]"+enums.clientType(strings.TrimPrefix(s.Output, "[]")), nil)
+		res = append(res, fmt.Sprintf("const res = await this.callJSON<{ list: %s | null }>%s;", ret, call))
+		res = append(res, "return res.list ?? [];")
+	default:
+		ret = enums.tsType(enums.clientType(s.Output), nil)
+		res = append(res, fmt.Sprintf("const res = await this.callJSON<{ value: %s }>%s;", ret, call))
+		res = append(res, "return res.value;")
+	}
+	var params string
+	if clientParams(name, handlerTypes) != "" {
+		params = fmt.Sprintf("req: %sRequest, ", name)
+	}
+	return tsFunc(name, params, ret, res)
+}
+
+func tsFunc(name, params, ret string, body []string) []string {
+	res := []string{fmt.Sprintf("  async %s(%ssignal?: AbortSignal): Promise<%s> {", converter.LowerFirstCharacter(name), params, ret)}
+	for _, line := range body {
+		res = append(res, "    "+line)
+	}
+	return append(res, "  }")
+}
+
+func tsBatchMethod(s *batchService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	ret := name + "Result[]"
+	return tsFunc(name, fmt.Sprintf("items: %sRequest[], ", name), ret, []string{
+		fmt.Sprintf("return this.callJSON<%s>(\"POST\", %q, undefined, items, signal);", ret, s.BatchPath()),
+	})
+}
+
+func tsCopyFromMethod(s *copyFromService) []string {
+	name := converter.UpperFirstCharacter(s.Name)
+	return tsFunc(name, fmt.Sprintf("rows: %sRequest[], ", name), name+"Response", []string{
+		`const body = rows.map((row) => JSON.stringify(row) + "\n").join("");`,
+		fmt.Sprintf("const res = await this.send(\"POST\", %q, undefined, \"application/x-ndjson\", body, signal);", s.BulkPath()),
+		fmt.Sprintf("return (await res.json()) as %sResponse;", name),
+	})
+}
+
+func tsStreamMethod(s *streamService, enums serverEnums) []string {
+	method := httpmetadata.HttpMethod(s.Service)
+	name := converter.UpperFirstCharacter(s.Name)
+	args := tsRequest(method, httpmetadata.HttpPath(s.Service), clientFields(s.Service))
+	row := name + "Response"
+	if !s.HasCustomOutput() {
+		row = enums.tsType(enums.clientType(s.Output), nil)
+	}
+	res := []string{fmt.Sprintf("return this.stream<%s>(%q, %s, fn, signal);", row, method, args)}
+	var params string
+	if clientParams(name, httpmetadata.HandlerTypes(s.Service)) != "" {
+		params = fmt.Sprintf("req: %sRequest, ", name)
+	}
+	params += fmt.Sprintf("fn: (row: %s) => void | Promise<void>, ", row)
+	return tsFunc(name, params, "void", res)
+}
+
+func tsPageMethod(s *pageService) []string {
+	method := httpmetadata.HttpMethod(s.Service)
+	name := converter.UpperFirstCharacter(s.Name)
+	fields := append(clientFields(s.Service),
+		clientField{Name: "PageSize", Type: "int32", Param: "page_size"},
+		clientField{Name: "PageToken", Type: "string", Param: "page_token"})
+	args := tsRequest(method, httpmetadata.HttpPath(s.Service), fields)
+	return tsFunc(name, fmt.Sprintf("req: %sRequest, ", name), name+"Page", []string{
+		fmt.Sprintf("return this.callJSON<%sPage>(%q, %s, signal);", name, method, args),
+	})
+}
@@ -0,0 +1,354 @@
+package golang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+	"github.com/walterwanderley/sqlc-grpc/converter"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// serverValidator checks the parameters of a query against the constraints of
+// their columns, before calling the database. The params struct is checked by
+// its Validate method and a single parameter by the validate<Name> function.
+type serverValidator struct {
+	Service string
+	// Params is the params struct of the query, empty for a single parameter.
+	Params string
+	// Input is the name of the single parameter.
+	Input     string
+	InputType string
+	Fields    []*validatedField
+}
+
+// validatedField is a parameter, or a field of the params struct, with at
+// least one constraint.
+type validatedField struct {
+	// Name is the Go name of the field or parameter.
+	Name string
+	// Field is the name of the field in the requests and in the errors.
+	Field string
+	Type  string
+	// Required is set for the NOT NULL columns whose Go type has an invalid
+	// zero value (empty string, nil UUID or invalid pgtype).
+	Required bool
+	// MaxLength is the length of the varchar and char columns.
+	MaxLength int
+	Enum      *serverEnum
+}
+
+// toServerValidator returns the validator of the query parameters, or false
+// if no parameter has a constraint.
+func toServerValidator(query Query, enums serverEnums) (*serverValidator, bool) {
+	v := serverValidator{Service: query.MethodName}
+	switch {
+	case query.Arg.isEmpty():
+		return nil, false
+	case query.Arg.Struct != nil:
+		if !query.Arg.EmitStruct() {
+			// the parameters are passed one by one to the Queries method
+			return nil, false
+		}
+		v.Params = query.Arg.Struct.Name
+		for _, f := range query.Arg.UniqueFields() {
+			if field, ok := toValidatedField(f.Name, f.Type, f.Column, enums); ok {
+				v.Fields = append(v.Fields, field)
+			}
+		}
+	default:
+		v.Input = query.Arg.Name
+		v.InputType = query.Arg.Type()
+		if field, ok := toValidatedField(query.Arg.Name, query.Arg.Type(), query.Arg.Column, enums); ok {
+			v.Fields = append(v.Fields, field)
+		}
+	}
+	if len(v.Fields) == 0 {
+		return nil, false
+	}
+	return &v, true
+}
+
+func toValidatedField(name, typ string, col *plugin.Column, enums serverEnums) (*validatedField, bool) {
+	if col == nil {
+		return nil, false
+	}
+	f := validatedField{
+		Name:  name,
+		Field: converter.ToSnakeCase(converter.CanonicalName(name)),
+		Type:  typ,
+	}
+	elemType := strings.TrimPrefix(typ, "[]")
+	if e, _, ok := enums.lookup(elemType); ok {
+		f.Enum = e
+	}
+	if col.Length > 0 && isStringType(elemType) {
+		f.MaxLength = int(col.Length)
+	}
+	if col.NotNull && !strings.HasPrefix(typ, "[]") {
+		switch {
+		case typ == "string", typ == "uuid.UUID", strings.HasPrefix(typ, "pgtype."), f.Enum != nil:
+			f.Required = true
+		}
+	}
+	if !f.Required && f.MaxLength == 0 && f.Enum == nil {
+		return nil, false
+	}
+	return &f, true
+}
+
+func isStringType(typ string) bool {
+	switch typ {
+	case "string", "*string", "pgtype.Text", "sql.NullString":
+		return true
+	}
+	return false
+}
+
+// value returns the expression of the field in the Validate method, or the
+// parameter of the validate<Name> function.
+func (v *serverValidator) value(name string) string {
+	if v.Params != "" {
+		return "arg." + name
+	}
+	return name
+}
+
+// Validate returns the declaration of the Validate method of the params
+// struct, or of the validate<Name> function.
+func (v *serverValidator) Validate() []string {
+	res := make([]string, 0)
+	if v.Params != "" {
+		res = append(res, fmt.Sprintf("// Validate checks the constraints of the columns of the %s parameters.", v.Service))
+		res = append(res, fmt.Sprintf("func (arg %s) Validate() error {", v.Params))
+	} else {
+		res = append(res, fmt.Sprintf("// %s checks the constraints of the column of the %s parameter.", v.funcName(), v.Service))
+		res = append(res, fmt.Sprintf("func %s(%s %s) error {", v.funcName(), v.Input, v.InputType))
+	}
+	res = append(res, "var errs validation.Errors")
+	for _, f := range v.Fields {
+		res = append(res, f.checks(v.value(f.Name))...)
+	}
+	res = append(res, "if len(errs) > 0 {")
+	res = append(res, "return errs")
+	res = append(res, "}")
+	res = append(res, "return nil")
+	res = append(res, "}")
+	return res
+}
+
+func (v *serverValidator) funcName() string {
+	return "validate" + converter.UpperFirstCharacter(v.Service)
+}
+
+// call returns the expression validating the parameters of the service.
+func (v *serverValidator) call(s *metadata.Service) string {
+	if v.Params != "" {
+		return s.InputNames[0] + ".Validate()"
+	}
+	return fmt.Sprintf("%s(%s)", v.funcName(), s.InputNames[0])
+}
+
+func fieldError(field, description string) string {
+	return fmt.Sprintf("errs = append(errs, validation.FieldError{Field: %s, Description: %q})", field, description)
+}
+
+// checks returns the lines appending the errors of the field value to errs.
+func (f *validatedField) checks(value string) []string {
+	if strings.HasPrefix(f.Type, "[]") {
+		elem := f.elemChecks("v", "fmt.Sprintf(\""+f.Field+"[%d]\", i)", strings.TrimPrefix(f.Type, "[]"))
+		if len(elem) == 0 {
+			return nil
+		}
+		res := []string{fmt.Sprintf("for i, v := range %s {", value)}
+		res = append(res, elem...)
+		return append(res, "}")
+	}
+	field := fmt.Sprintf("%q", f.Field)
+	if f.Enum != nil {
+		return f.enumChecks(value, field)
+	}
+	res := make([]string, 0)
+	if f.Required {
+		var cond string
+		switch {
+		case f.Type == "string":
+			cond = value + ` == ""`
+		case f.Type == "uuid.UUID":
+			cond = value + " == uuid.Nil"
+		default:
+			cond = "!" + value + ".Valid"
+		}
+		res = append(res, fmt.Sprintf("if %s {", cond))
+		res = append(res, fieldError(field, "is required"))
+		res = append(res, "}")
+	}
+	return append(res, f.elemChecks(value, field, f.Type)...)
+}
+
+// elemChecks returns the checks of the length or of the enum of a value.
+func (f *validatedField) elemChecks(value, field, typ string) []string {
+	if f.Enum != nil {
+		return f.enumChecks(value, field)
+	}
+	if f.MaxLength == 0 {
+		return nil
+	}
+	var cond string
+	switch typ {
+	case "string":
+		cond = fmt.Sprintf("utf8.RuneCountInString(%s) > %d", value, f.MaxLength)
+	case "*string":
+		cond = fmt.Sprintf("%s != nil && utf8.RuneCountInString(*%s) > %d", value, value, f.MaxLength)
+	default:
+		cond = fmt.Sprintf("%s.Valid && utf8.RuneCountInString(%s.String) > %d", value, value, f.MaxLength)
+	}
+	return []string{
+		fmt.Sprintf("if %s {", cond),
+		fieldError(field, fmt.Sprintf("must have at most %d characters", f.MaxLength)),
+		"}",
+	}
+}
+
+// enumChecks returns the check of the values of the enum. The invalid
+// Null<Name> values are NULL and aren't checked.
+func (f *validatedField) enumChecks(value, field string) []string {
+	e := f.Enum
+	res := make([]string, 0)
+	typ := strings.TrimPrefix(f.Type, "[]")
+	if typ == "Null"+e.Name {
+		res = append(res, fmt.Sprintf("if %s.Valid {", value))
+		value = value + "." + e.Name
+	}
+	constants := make([]string, 0, len(e.Values))
+	values := make([]string, 0, len(e.Values))
+	var emptyValue bool
+	for _, v := range e.Values {
+		constants = append(constants, v.Constant)
+		values = append(values, v.Value)
+		emptyValue = emptyValue || v.Value == ""
+	}
+	res = append(res, fmt.Sprintf("switch %s {", value))
+	if f.Required && !emptyValue {
+		res = append(res, `case "":`)
+		res = append(res, fieldError(field, "is required"))
+	}
+	res = append(res, fmt.Sprintf("case %s:", strings.Join(constants, ", ")))
+	res = append(res, "default:")
+	res = append(res, fieldError(field, "must be one of "+strings.Join(values, ", ")))
+	res = append(res, "}")
+	if typ == "Null"+e.Name {
+		res = append(res, "}")
+	}
+	return res
+}
+
+// serverValidators are the validators of the services of the package.
+type serverValidators []*serverValidator
+
+func (validators serverValidators) sort() {
+	sort.SliceStable(validators, func(i, j int) bool {
+		return strings.Compare(validators[i].Service, validators[j].Service) < 0
+	})
+}
+
+func (validators serverValidators) lookup(s *metadata.Service) (*serverValidator, bool) {
+	for _, v := range validators {
+		if v.Service == s.Name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// input returns the lines validating the input of the service, handling the
+// error with the onError lines.
+func (validators serverValidators) input(s *metadata.Service, onError ...string) []string {
+	v, ok := validators.lookup(s)
+	if !ok || s.EmptyInput() {
+		return nil
+	}
+	res := []string{fmt.Sprintf("if err := %s; err != nil {", v.call(s))}
+	res = append(res, onError...)
+	return append(res, "}")
+}
+
+// inputGrpc validates the input of the service, returning the InvalidArgument
+// error with the field violations. It's shared by the grpc and connect
+// servers.
+func (validators serverValidators) inputGrpc(s *metadata.Service) []string {
+	return validators.input(s, "return nil, validation.InvalidArgument(err)")
+}
+
+// inputHttp validates the input of the service, responding 400 with the field
+// errors.
+func (validators serverValidators) inputHttp(s *metadata.Service) []string {
+	return validators.input(s, "server.Encode(w, r, http.StatusBadRequest, err)", "return")
+}
+
+// apiSchema adds the constraints of the fields to the OpenAPI schemas of the
+// request described by the lines: the required parameters and properties and
+// the maxLength of the strings.
+func (validators serverValidators) apiSchema(s *metadata.Service, lines []string) []string {
+	v, ok := validators.lookup(s)
+	if !ok {
+		return lines
+	}
+	fields := make(map[string]*validatedField, len(v.Fields))
+	for _, f := range v.Fields {
+		fields[f.Field] = f
+	}
+	res := make([]string, 0, len(lines))
+	var (
+		pending *validatedField
+		param   bool
+	)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		if trimmed == "properties:" {
+			required := make([]string, 0)
+			for _, prop := range lines[i+1:] {
+				propIndent := len(prop) - len(strings.TrimLeft(prop, " "))
+				if propIndent <= len(indent) {
+					break
+				}
+				name, ok := strings.CutSuffix(strings.TrimSpace(prop), ":")
+				if f, found := fields[name]; ok && found && propIndent == len(indent)+2 && f.Required {
+					required = append(required, name)
+				}
+			}
+			if len(required) > 0 {
+				res = append(res, indent+"required:")
+				for _, name := range required {
+					res = append(res, indent+"  - "+name)
+				}
+			}
+		}
+		res = append(res, line)
+		name, isParam := strings.CutPrefix(trimmed, "- name: ")
+		if isParam || (strings.HasSuffix(trimmed, ":") && !strings.ContainsAny(trimmed, " \"")) {
+			if f, ok := fields[strings.TrimSuffix(name, ":")]; ok {
+				pending, param = f, isParam
+			}
+			continue
+		}
+		if pending == nil {
+			continue
+		}
+		if param && strings.HasPrefix(trimmed, "in: ") {
+			if pending.Required {
+				res = append(res, indent+"required: true")
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "type: ") {
+			if pending.MaxLength > 0 && trimmed == "type: string" && !strings.HasPrefix(pending.Type, "[]") {
+				res = append(res, fmt.Sprintf("%smaxLength: %d", indent, pending.MaxLength))
+			}
+			pending = nil
+		}
+	}
+	return res
+}
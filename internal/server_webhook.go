@@ -0,0 +1,233 @@
+package golang
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sqlc-dev/plugin-sdk-go/plugin"
+	"github.com/walterwanderley/sqlc-grpc/metadata"
+)
+
+// serverWebhooks are the webhook events triggered by the services, by service
+// name, from the comments of the queries like "-- webhook: authors.created".
+type serverWebhooks map[string][]string
+
+var webhookEventName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:-]*$`)
+
+// parseWebhookEvents returns the events of a webhook comment, separated by
+// commas.
+func parseWebhookEvents(spec string) ([]string, error) {
+	events := make([]string, 0)
+	for _, event := range strings.Split(spec, ",") {
+		event = strings.TrimSpace(event)
+		if !webhookEventName.MatchString(event) {
+			return nil, fmt.Errorf("invalid webhook event %q, use names like authors.created separated by commas", event)
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// webhookEvent is an event of the webhooks and the services triggering it.
+type webhookEvent struct {
+	Name     string
+	Services []string
+}
+
+// events returns the events sorted by name, listed by the webhook package.
+func (w serverWebhooks) events() []webhookEvent {
+	byName := make(map[string][]string)
+	for service, events := range w {
+		for _, event := range events {
+			byName[event] = append(byName[event], service)
+		}
+	}
+	res := make([]webhookEvent, 0, len(byName))
+	for name, services := range byName {
+		sort.Strings(services)
+		res = append(res, webhookEvent{Name: name, Services: services})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// webhookTemplatesFS adds the webhook package to the templates.
+func webhookTemplatesFS(base fs.FS) (fs.FS, error) {
+	top, err := fs.Sub(serverTemplates, "server_templates/webhook")
+	if err != nil {
+		return nil, err
+	}
+	return overlayFS{top: top, base: base}, nil
+}
+
+// webhookTable returns the DDL of the table of the webhook subscriptions.
+func webhookTable(engine string) string {
+	switch engine {
+	case "postgresql":
+		return `CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+	case "mysql":
+		return `CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	url VARCHAR(2048) NOT NULL,
+	secret VARCHAR(255) NOT NULL,
+	events TEXT NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+	}
+	return `CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+}
+
+// webhookMigrations returns the migration of the webhook subscriptions in
+// the migration_path.
+func webhookMigrations(def *metadata.Definition, toRootPath, version string) []*plugin.File {
+	up := webhookTable(def.Database()) + ";\n"
+	down := "DROP TABLE IF EXISTS webhook_subscriptions;\n"
+	return migrationFiles(def, toRootPath, version, "webhook_subscriptions", up, down)
+}
+
+// webhookFuncs adds the WebhookEnabled, WebhookEvents, WebhookTable and
+// TxWebhooks functions, and passes the response of the services annotated
+// with a webhook comment to webhook.Notify, sending it to the subscriptions
+// of the events after the query succeeds. The http handlers without a
+// response send a null payload. It returns an error if the response of a
+// service can't be passed to webhook.Notify.
+func webhookFuncs(funcs template.FuncMap, serverType, engine string, pkg *serverPackage) (template.FuncMap, error) {
+	webhooks := pkg.Webhooks
+	res := maps.Clone(funcs)
+	res["WebhookEnabled"] = func() bool { return len(webhooks) > 0 }
+	res["WebhookEvents"] = webhooks.events
+	res["WebhookTable"] = func() string { return webhookTable(engine) }
+	res["TxWebhooks"] = func(t *transactionService) []string { return transactionWebhooks(t, webhooks) }
+	if len(webhooks) == 0 {
+		return res, nil
+	}
+	output, ok := funcs["Output"].(func(*metadata.Service) []string)
+	if !ok {
+		return nil, fmt.Errorf("the webhook comments aren't supported by the %s server type", serverType)
+	}
+	for _, s := range pkg.Services {
+		if events, ok := webhooks[s.Name]; ok {
+			if _, ok := notifyOutput(serverType, output(s), events); !ok {
+				return nil, fmt.Errorf("query %s: the response of the service can't be sent to the webhooks", s.Name)
+			}
+		}
+	}
+	res["Output"] = func(s *metadata.Service) []string {
+		lines := output(s)
+		if events, ok := webhooks[s.Name]; ok {
+			// the outputs were checked above
+			lines, _ = notifyOutput(serverType, lines, events)
+		}
+		return lines
+	}
+	return res, nil
+}
+
+// notifyOutput passes the response of the output lines of a service to
+// webhook.Notify, or calls webhook.Send with a null payload for the http
+// handlers without a response. It reports false if the response isn't found.
+func notifyOutput(serverType string, lines, events []string) ([]string, bool) {
+	args := webhookArgs(events)
+	lines = slices.Clone(lines)
+	switch serverType {
+	case "http":
+		const encode = "server.Encode(w, r, http.StatusOK, "
+		i := slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, encode) })
+		if i < 0 {
+			return append(lines, fmt.Sprintf("webhook.Send(r.Context(), nil, %s)", args)), true
+		}
+		// the response may span several lines, closed by the line balancing
+		// the parentheses of the call
+		end, depth := i, 0
+		for ; end < len(lines); end++ {
+			if depth += strings.Count(lines[end], "(") - strings.Count(lines[end], ")"); depth == 0 {
+				break
+			}
+		}
+		if end == len(lines) {
+			return nil, false
+		}
+		lines[end] = strings.TrimSuffix(lines[end], ")") + ", " + args + "))"
+		lines[i] = encode + "webhook.Notify(r.Context(), " + strings.TrimPrefix(lines[i], encode)
+		return lines, true
+	}
+	last := len(lines) - 1
+	if last < 0 {
+		return nil, false
+	}
+	v, ok := strings.CutSuffix(strings.TrimPrefix(lines[last], "return "), ", nil")
+	if !ok {
+		return nil, false
+	}
+	if inner, ok := strings.CutPrefix(v, "connect.NewResponse("); ok {
+		lines[last] = fmt.Sprintf("return connect.NewResponse(webhook.Notify(ctx, %s, %s)), nil", strings.TrimSuffix(inner, ")"), args)
+	} else {
+		lines[last] = fmt.Sprintf("return webhook.Notify(ctx, %s, %s), nil", v, args)
+	}
+	return lines, true
+}
+
+// transactionWebhooks returns the calls of webhook.Send for the steps of the
+// transaction annotated with a webhook comment, run after the commit with
+// the result of each step, or once per item of the repeated steps.
+func transactionWebhooks(t *transactionService, webhooks serverWebhooks) []string {
+	res := make([]string, 0)
+	for _, s := range t.Steps {
+		events, ok := webhooks[s.Name]
+		if !ok {
+			continue
+		}
+		args := webhookArgs(events)
+		switch {
+		case s.Repeated && s.HasResponse():
+			res = append(res, fmt.Sprintf("for _, v := range res.%s {", s.Field()))
+			res = append(res, fmt.Sprintf("webhook.Send(ctx, v, %s)", args))
+			res = append(res, "}")
+		case s.Repeated:
+			res = append(res, fmt.Sprintf("for range %sArgs {", s.Var()))
+			res = append(res, fmt.Sprintf("webhook.Send(ctx, nil, %s)", args))
+			res = append(res, "}")
+		case s.HasResponse():
+			res = append(res, fmt.Sprintf("webhook.Send(ctx, res.%s, %s)", s.Field(), args))
+		default:
+			res = append(res, fmt.Sprintf("webhook.Send(ctx, nil, %s)", args))
+		}
+	}
+	if len(res) > 0 {
+		res = append([]string{"// the steps notify the webhooks once the transaction is committed"}, res...)
+	}
+	return res
+}
+
+// webhookArgs returns the events as the arguments of webhook.Notify and
+// webhook.Send.
+func webhookArgs(events []string) string {
+	args := make([]string, 0, len(events))
+	for _, event := range events {
+		args = append(args, fmt.Sprintf("%q", event))
+	}
+	return strings.Join(args, ", ")
+}
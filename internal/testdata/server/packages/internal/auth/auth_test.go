@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func signHS256(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	v := jwtVerifier{secret: []byte("s3cr3t"), rolesClaim: "roles"}
+
+	p, err := v.verify(signHS256(t, "s3cr3t", map[string]any{"sub": "ana", "roles": []string{"admin"}, "exp": now.Add(time.Hour).Unix()}), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "ana" || !p.HasRole("admin") {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	for name, token := range map[string]string{
+		"other secret": signHS256(t, "other", map[string]any{"sub": "ana"}),
+		"expired":      signHS256(t, "s3cr3t", map[string]any{"sub": "ana", "exp": now.Add(-time.Hour).Unix()}),
+		"not yet":      signHS256(t, "s3cr3t", map[string]any{"sub": "ana", "nbf": now.Add(time.Hour).Unix()}),
+		"invalid exp":  signHS256(t, "s3cr3t", map[string]any{"sub": "ana", "exp": "tomorrow"}),
+		"none":         base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"ana"}`)) + ".",
+		"malformed":    "token",
+	} {
+		if _, err := v.verify(token, now); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s: expected an unauthenticated error, got %v", name, err)
+		}
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	sum := sha256.Sum256([]byte("k3y"))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`[{"sha256": "`+hex.EncodeToString(sum[:])+`", "subject": "billing", "roles": ["admin"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AUTH_API_KEYS_FILE", path)
+	authenticate, err := loadAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := func(key string) func(string) string {
+		return func(name string) string {
+			if name == APIKeyHeader {
+				return key
+			}
+			return ""
+		}
+	}
+	p, err := authenticate(header("k3y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "billing" || !p.HasRole("admin") {
+		t.Errorf("unexpected principal %+v", p)
+	}
+	if _, err := authenticate(header("other")); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected an unauthenticated error, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	want := "sha256=482d731874034ab9787715174409d9a9ac267ec2590b2b5326e43ed9d7c11646"
+	if got := Sign("s3cr3t", "1700000000", []byte(`{"id":"1"}`)); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestDial(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "[::1]:80", "10.1.2.3:80", "192.168.0.1:80", "169.254.169.254:80", "100.64.0.1:80", "0.0.0.0:80", "[::ffff:127.0.0.1]:80"} {
+		if _, err := dial(context.Background(), "tcp", addr); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: expected a forbidden address error, got %v", addr, err)
+		}
+	}
+}
+
+func TestPost(t *testing.T) {
+	var signature, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		if r.Header.Get("X-Webhook-Signature") == Sign("s3cr3t", r.Header.Get("X-Webhook-Timestamp"), b) {
+			signature = "valid"
+		}
+	}))
+	defer srv.Close()
+	sub := Subscription{ID: 1, URL: srv.URL, Secret: "s3cr3t"}
+	d := &delivery{ID: "1", Event: "authors.created", Data: []byte(`{"id":1}`)}
+
+	// the loopback address of the test server isn't public
+	retry, err := d.post(context.Background(), sub, []byte(`{}`))
+	if !errors.Is(err, ErrForbiddenAddress) || retry {
+		t.Fatalf("expected a forbidden address error without retry, got %v, retry %v", err, retry)
+	}
+
+	host, _, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedHosts = []string{host}
+	defer func() { allowedHosts = nil }()
+	if _, err := d.post(context.Background(), sub, []byte(`{"id":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"id":"1"}` || signature != "valid" {
+		t.Errorf("unexpected delivery %q with a %q signature", body, signature)
+	}
+}